@@ -8,9 +8,16 @@ import (
 	"gw-currency-wallet/internal/config"
 	"gw-currency-wallet/internal/grpcClient/auth"
 	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/internal/health"
+	"gw-currency-wallet/internal/metrics"
+	ratelimit_in_mem "gw-currency-wallet/internal/ratelimit/in-mem"
+	"gw-currency-wallet/internal/rates"
 	"gw-currency-wallet/internal/storages/postgres"
+	"gw-currency-wallet/internal/tokens"
+	"gw-currency-wallet/internal/tracing"
 	"gw-currency-wallet/internal/web"
 	"gw-currency-wallet/pkg/logs"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -30,65 +37,135 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 	defer stop()
 
-	logger := logs.New(os.Stdout)
+	logger := logs.New(os.Stdout, logs.FormatText, slog.LevelInfo)
 
 	confPath := flag.String("c", "config.env", "path to configuration")
 	migrationPath := flag.String("m", "migrations", "path to migration DB files")
 	flag.Parse()
 
 	if err := config.LoadConfig(*confPath); err != nil {
-		logger.Err("read configuration", err)
+		logger.Err(ctx, "read configuration", err)
 		return
 	}
 
-	cfg := config.New()
+	cfg, err := config.New()
+	if err != nil {
+		logger.Err(ctx, "read configuration", err)
+		return
+	}
+
+	logLevel, err := logs.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		logger.Err(ctx, "read log level", err)
+		return
+	}
+	logger = logs.New(os.Stdout, cfg.Log.Format, logLevel)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logger.Err(ctx, "setup tracing", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Err(ctx, "shutdown tracing", err)
+		}
+	}()
 
 	dbUrl, err := cfg.Postgres.ConnectionURL()
 	if err != nil {
-		logger.Err("read db url", err)
+		logger.Err(ctx, "read db url", err)
 		return
 	}
 
 	db := postgres.New()
 
-	if err = db.Start(ctx, dbUrl, time.Duration(cfg.Postgres.ConnTimeout)*time.Second, *migrationPath); err != nil {
-		logger.Err("connection db", err)
+	if err = db.Start(ctx, dbUrl, time.Duration(cfg.Postgres.ConnTimeout)*time.Second,
+		time.Duration(cfg.Postgres.QueryTimeout)*time.Second, *migrationPath); err != nil {
+		logger.Err(ctx, "connection db", err)
 		return
 	}
 	defer db.Stop()
 
-	exchger := exchange.New(cfg.Exchanger.ConnectionURL())
+	if err = metrics.RegisterPool(db); err != nil {
+		logger.Err(ctx, "register db metrics", err)
+		return
+	}
+
+	exchger := exchange.New(cfg.Exchanger.ConnectionURL(), time.Duration(cfg.Exchanger.Timeout)*time.Second)
 	if err = exchger.Run(); err != nil {
-		logger.Err("connection exchange", err)
+		logger.Err(ctx, "connection exchange", err)
 		return
 	}
 	defer exchger.Stop()
 
-	authorizer := auth.New(cfg.Auth.ConnectionURL())
+	authorizer := auth.New(cfg.Auth.ConnectionURL(), time.Duration(cfg.Auth.Timeout)*time.Second)
 	if err = authorizer.Run(); err != nil {
-		logger.Err("connection authorizer", err)
+		logger.Err(ctx, "connection authorizer", err)
 		return
 	}
 	defer exchger.Stop()
 
-	cache := in_mem.New(60 * time.Second)
+	cache := in_mem.New(60*time.Second, in_mem.Hooks{
+		OnHit:  metrics.CacheHit,
+		OnMiss: metrics.CacheMiss,
+	})
+
+	rateProvider := rates.New(exchger,
+		time.Duration(cfg.Rates.RefreshInterval)*time.Second,
+		time.Duration(cfg.Rates.Timeout)*time.Second,
+		time.Duration(cfg.Rates.MaxStaleness)*time.Second,
+		logger)
+	go rateProvider.Run(ctx)
+
+	var verifier *tokens.Verifier
+	if cfg.JWT.LocalVerification() {
+		if verifier, err = tokens.New(cfg.JWT, logger); err != nil {
+			logger.Err(ctx, "token verifier", err)
+			return
+		}
+		go verifier.Run(ctx)
+	}
+
+	srv, err := app.New(ctx, cfg, db, cache, rateProvider, authorizer, verifier, ratelimit_in_mem.New(), logger)
+	if err != nil {
+		logger.Err(ctx, "create app", err)
+		return
+	}
+	go srv.RunCleanup(ctx, time.Hour)
 
-	srv := app.New(ctx, db, cache, exchger, authorizer, logger)
+	probes := health.New(time.Duration(cfg.Web.ReadinessTimeout) * time.Second)
+	probes.Add("postgres", db.Ping)
+	probes.Add("exchanger", exchger.Check)
+	probes.Add("authorizer", authorizer.Check)
 
-	webSrv := web.New(cfg.Web.ConnectionURL(), srv)
+	webSrv, err := web.New(cfg.Web.ConnectionURL(), cfg.Web.Proxies(), srv, probes, logger, cfg.AccessLog)
+	if err != nil {
+		logger.Err(ctx, "create web server", err)
+		return
+	}
 
 	go func() {
 		<-ctx.Done()
+
+		// Fail readiness first and give the orchestrator time to stop routing
+		// traffic here, then drain the requests in flight.
+		probes.Shutdown()
+		time.Sleep(time.Duration(cfg.Web.ShutdownDelay) * time.Second)
+
 		if err = webSrv.Stop(); err != nil {
-			logger.Err("Closing web server", err)
+			logger.Err(ctx, "Closing web server", err)
 			return
 		}
-		logger.Info("Closing", logs.Attr{Key: "code", Value: "0"})
+		logger.Info(ctx, "Closing", "code", 0)
 	}()
 
 	err = webSrv.Start()
 	if err != nil {
-		logger.Err("Start web server", err)
+		logger.Err(ctx, "Start web server", err)
 		return
 	}
 
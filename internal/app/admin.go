@@ -0,0 +1,584 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+const (
+	auditWalletView         = "wallet.view"
+	auditWalletTransactions = "wallet.transactions"
+	auditWalletFreeze       = "wallet.freeze"
+	auditWalletUnfreeze     = "wallet.unfreeze"
+	auditWalletClose        = "wallet.close"
+	auditWalletTier         = "wallet.tier"
+	auditWalletAdjust       = "wallet.adjust"
+)
+
+var (
+	forbiddenErr      = fmt.Errorf("forbidden")
+	reasonRequiredErr = fmt.Errorf("reason is required")
+	unknownTierErr    = fmt.Errorf("unknown tier")
+)
+
+// HasRole reports whether the principal has any of the roles.
+func (p Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireSupport lets support staff and admins through.
+func (a *App) RequireSupport(c *gin.Context) {
+	requireRole(c, RoleSupport, RoleAdmin)
+}
+
+func (a *App) RequireAdmin(c *gin.Context) {
+	requireRole(c, RoleAdmin)
+}
+
+func requireRole(c *gin.Context, roles ...string) {
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		writeError(c, unauthenticatedErr)
+		c.Abort()
+		return
+	}
+
+	if !principal.HasRole(roles...) {
+		writeError(c, forbiddenErr)
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+// @Summary Admin wallet
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion wallet status and balance of any user; support or admin role
+// @ID admin-wallet
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id} [get]
+func (a *App) AdminWallet(c *gin.Context) {
+	const op = "App AdminWallet"
+
+	ctx := c.Request.Context()
+
+	actor, err := currentUser(c)
+	if err != nil {
+		return
+	}
+	user := c.Param("user_id")
+
+	wallet, err := a.storage.GetWallet(ctx, user)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if err = a.audit(ctx, actor, auditWalletView, user, "", nil); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, walletResponse(wallet, currencies))
+}
+
+// @Summary Admin transactions
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion transaction history of any wallet; support or admin role
+// @ID admin-wallet-transactions
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param currency query string false "currency code"
+// @Param kind query string false "deposit, withdraw, exchange, transfer_in, transfer_out, fee or adjustment"
+// @Param from query string false "start of the period (RFC3339 or YYYY-MM-DD), inclusive"
+// @Param to query string false "end of the period (RFC3339 or YYYY-MM-DD), exclusive; a date includes the whole day"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} TransactionsResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/transactions [get]
+func (a *App) AdminTransactions(c *gin.Context) {
+	const op = "App AdminTransactions"
+
+	ctx := c.Request.Context()
+
+	actor, err := currentUser(c)
+	if err != nil {
+		return
+	}
+	user := c.Param("user_id")
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		a.sendError(c, op, badRequest(err))
+		return
+	}
+
+	if _, err = a.storage.GetWallet(ctx, user); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if err = a.audit(ctx, actor, auditWalletTransactions, user, "", map[string]any{"query": c.Request.URL.RawQuery}); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	a.writeTransactions(c, user, filter)
+}
+
+// @Summary Freeze wallet
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion block withdrawals, outgoing transfers and exchanges; deposits and reads still work; admin role
+// @ID admin-freeze-wallet
+// @Accept json
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param input body AdminActionRequest true "reason"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 409 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/freeze [post]
+func (a *App) FreezeWallet(c *gin.Context) {
+	a.setWalletStatus(c, storages.WalletFrozen, auditWalletFreeze)
+}
+
+// @Summary Unfreeze wallet
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion allow money movement on a frozen wallet again; admin role
+// @ID admin-unfreeze-wallet
+// @Accept json
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param input body AdminActionRequest true "reason"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 409 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/unfreeze [post]
+func (a *App) UnfreezeWallet(c *gin.Context) {
+	a.setWalletStatus(c, storages.WalletActive, auditWalletUnfreeze)
+}
+
+// @Summary Close wallet
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion close a wallet for good: no money movement, balance reads only; admin role
+// @ID admin-close-wallet
+// @Accept json
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param input body AdminActionRequest true "reason"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 409 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/close [post]
+func (a *App) CloseWallet(c *gin.Context) {
+	a.setWalletStatus(c, storages.WalletClosed, auditWalletClose)
+}
+
+// setWalletStatus moves the wallet to the status. A closed wallet stays closed.
+func (a *App) setWalletStatus(c *gin.Context, status, action string) {
+	const op = "App setWalletStatus"
+
+	ctx := c.Request.Context()
+
+	actor, err := currentUser(c)
+	if err != nil {
+		return
+	}
+	user := c.Param("user_id")
+
+	var request AdminActionRequest
+
+	if err = c.ShouldBindJSON(&request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	if request.Reason = strings.TrimSpace(request.Reason); request.Reason == "" {
+		a.sendError(c, op, reasonRequiredErr)
+		return
+	}
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	var response WalletResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		wallet, err := lockWallet(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		previous := wallet.Status
+
+		switch previous {
+		case storages.WalletClosed:
+			return walletAlreadyClosedErr
+		case status:
+			return walletStatusUnchangedErr
+		}
+
+		if err = tx.SetWalletStatus(ctx, user, status); err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(map[string]any{"from": previous, "to": status})
+		if err != nil {
+			return err
+		}
+
+		err = tx.AddAuditRecord(ctx, storages.AuditRecord{
+			ActorId: actor,
+			Action:  action,
+			UserId:  user,
+			Reason:  request.Reason,
+			Details: details,
+		})
+		if err != nil {
+			return err
+		}
+
+		wallet.Status = status
+		response = walletResponse(wallet, currencies)
+
+		return nil
+	})
+
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Set wallet tier
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion move a wallet to another limits tier; admin role
+// @ID admin-set-wallet-tier
+// @Accept json
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param input body TierRequest true "tier and reason"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/tier [post]
+func (a *App) SetWalletTier(c *gin.Context) {
+	const op = "App SetWalletTier"
+
+	ctx := c.Request.Context()
+
+	actor, err := currentUser(c)
+	if err != nil {
+		return
+	}
+	user := c.Param("user_id")
+
+	var request TierRequest
+
+	if err = c.ShouldBindJSON(&request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	if request.Reason = strings.TrimSpace(request.Reason); request.Reason == "" {
+		a.sendError(c, op, reasonRequiredErr)
+		return
+	}
+
+	request.Tier = strings.TrimSpace(request.Tier)
+	if !a.limits.knows(request.Tier) {
+		a.sendError(c, op, unknownTierErr)
+		return
+	}
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	var response WalletResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		wallet, err := lockWallet(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		previous := wallet.Tier
+
+		if err = tx.SetWalletTier(ctx, user, request.Tier); err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(map[string]any{"from": previous, "to": request.Tier})
+		if err != nil {
+			return err
+		}
+
+		err = tx.AddAuditRecord(ctx, storages.AuditRecord{
+			ActorId: actor,
+			Action:  auditWalletTier,
+			UserId:  user,
+			Reason:  request.Reason,
+			Details: details,
+		})
+		if err != nil {
+			return err
+		}
+
+		wallet.Tier = request.Tier
+		response = walletResponse(wallet, currencies)
+
+		return nil
+	})
+
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Adjust wallet
+// @Security ApiKeyAuth
+// @Tags Admin
+// @Descriotion manual correction of a balance, negative amount debits; admin role
+// @ID admin-adjust-wallet
+// @Accept json
+// @Produce json
+// @Param user_id path string true "wallet owner"
+// @Param input body AdjustmentRequest true "currency, signed amount and reason"
+// @Success 200 {object} WalletResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 409 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/admin/wallets/{user_id}/adjustments [post]
+func (a *App) AdjustWallet(c *gin.Context) {
+	const op = "App AdjustWallet"
+
+	ctx := c.Request.Context()
+
+	actor, err := currentUser(c)
+	if err != nil {
+		return
+	}
+	user := c.Param("user_id")
+
+	var request AdjustmentRequest
+
+	if err = c.ShouldBindJSON(&request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	if request.Reason = strings.TrimSpace(request.Reason); request.Reason == "" {
+		a.sendError(c, op, reasonRequiredErr)
+		return
+	}
+
+	request.Currency = strings.ToUpper(request.Currency)
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	currency, err := lookupCurrency(currencies, request.Currency)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if err = validateAmount(currency, request.Amount.Abs()); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	var response WalletResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		wallet, err := lockWallet(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		// A closed wallet is settled for good; a frozen one may still need a correction.
+		if wallet.Status == storages.WalletClosed {
+			return walletAlreadyClosedErr
+		}
+		balance := wallet.Balance
+
+		change, err := changeBalance(request.Currency, balance, request.Amount, decimal.NewFromInt(1))
+		if err != nil {
+			return err
+		}
+
+		if err = tx.UpdateWallet(ctx, user, storages.Balance{request.Currency: balance[request.Currency]}); err != nil {
+			return err
+		}
+
+		err = tx.AddTransactions(ctx, storages.Transaction{
+			UserId:   user,
+			Kind:     storages.KindAdjustment,
+			Currency: request.Currency,
+			Amount:   change,
+			Balance:  balance[request.Currency],
+		})
+		if err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(map[string]any{"currency": request.Currency, "amount": change, "balance": balance[request.Currency]})
+		if err != nil {
+			return err
+		}
+
+		err = tx.AddAuditRecord(ctx, storages.AuditRecord{
+			ActorId: actor,
+			Action:  auditWalletAdjust,
+			UserId:  user,
+			Reason:  request.Reason,
+			Details: details,
+		})
+		if err != nil {
+			return err
+		}
+
+		response = walletResponse(wallet, currencies)
+
+		return nil
+	})
+
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// lockWallet locks the wallet and reads its status and tier.
+func lockWallet(ctx context.Context, tx storages.Tx, user string) (storages.Wallet, error) {
+	balance, err := tx.GetBalanceForUpdate(ctx, user)
+	if err != nil {
+		return storages.Wallet{}, err
+	}
+
+	status, err := tx.GetWalletStatus(ctx, user)
+	if err != nil {
+		return storages.Wallet{}, err
+	}
+
+	tier, err := tx.GetWalletTier(ctx, user)
+	if err != nil {
+		return storages.Wallet{}, err
+	}
+
+	return storages.Wallet{UserId: user, Status: status, Tier: tier, Balance: balance}, nil
+}
+
+// walletResponse is the answer of every admin wallet route.
+func walletResponse(wallet storages.Wallet, currencies map[string]storages.Currency) WalletResponseJSON {
+	return WalletResponseJSON{
+		UserId:  wallet.UserId,
+		Status:  wallet.Status,
+		Tier:    wallet.Tier,
+		Balance: withEnabledCurrencies(wallet.Balance, currencies),
+	}
+}
+
+// audit records a read-only staff action. Changes are recorded in their own transaction instead.
+func (a *App) audit(ctx context.Context, actor, action, user, reason string, details map[string]any) error {
+	var raw []byte
+
+	if details != nil {
+		var err error
+		if raw, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+
+	return a.storage.AddAuditRecord(ctx, storages.AuditRecord{
+		ActorId: actor,
+		Action:  action,
+		UserId:  user,
+		Reason:  reason,
+		Details: raw,
+	})
+}
@@ -5,27 +5,82 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/shopspring/decimal"
 	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/config"
 	"gw-currency-wallet/internal/grpcClient/auth"
-	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/internal/metrics"
+	"gw-currency-wallet/internal/ratelimit"
+	"gw-currency-wallet/internal/rates"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/tokens"
 	"gw-currency-wallet/pkg/logs"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
 const patternToken = "[a-zA-Z0-9-_]+\\.[a-zA-Z0-9-_]+\\.[a-zA-Z0-9-_]+"
 
-func New(ctx context.Context, storage storages.Storage, cache cache.Cache, exchanger exchange.Exchanger, authorizer auth.Authorizer, logger *logs.Log) *App {
-	return &App{ctx: ctx,
+// statusClientClosedRequest is the nginx status for a request the client
+// abandoned before the response; nobody reads the response anyway.
+const statusClientClosedRequest = 499
+
+var (
+	insufficientFundsErr = fmt.Errorf("insufficient funds or invalid amount")
+	unknownCurrencyErr   = fmt.Errorf("unknown currency")
+	sameCurrencyErr      = fmt.Errorf("from_currency and to_currency must differ")
+	ratesUnavailableErr  = fmt.Errorf("exchange rates are unavailable")
+)
+
+func New(ctx context.Context, cfg config.Config, storage storages.Storage, cache cache.Cache, rateProvider *rates.Provider, authorizer auth.Authorizer, verifier *tokens.Verifier, limiter ratelimit.Store, logger *logs.Log) (*App, error) {
+	const op = "App New"
+
+	fees, err := newFees(cfg.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := newLimits(cfg.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits, err := ratelimit.ParseLimits(cfg.RateLimit.Default, cfg.RateLimit.Routes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: RATE_LIMIT: %w", op, err)
+	}
+
+	var ipRate ratelimit.Rate
+	if strings.TrimSpace(cfg.RateLimit.IP) != "" {
+		if ipRate, err = ratelimit.ParseRate(cfg.RateLimit.IP); err != nil {
+			return nil, fmt.Errorf("%s: RATE_LIMIT_IP: %w", op, err)
+		}
+	}
+
+	if cfg.Exchange.HouseWallet == "" {
+		return nil, fmt.Errorf("%s: EXCHANGE_HOUSE_WALLET is empty", op)
+	}
+
+	if err = storage.EnsureWallet(ctx, cfg.Exchange.HouseWallet); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &App{
+		cfg:        cfg,
+		fees:       fees,
+		limits:     limits,
 		storage:    storage,
 		cache:      cache,
-		exchanger:  exchanger,
+		rates:      rateProvider,
 		authorizer: authorizer,
+		tokens:     verifier,
+		limiter:    limiter,
+		rateLimits: rateLimits,
+		ipRate:     ipRate,
 		logger:     logger,
-	}
+	}, nil
 }
 
 // @Summary Registration
@@ -38,37 +93,33 @@ func New(ctx context.Context, storage storages.Storage, cache cache.Cache, excha
 // @Success 201 {object} MessageResponseJSON
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
 // @Router /api/v1/register [post]
 func (a *App) Register(c *gin.Context) {
 	const op = "App Register"
 
+	ctx := c.Request.Context()
+
 	var userRequest User
 
-	if err := c.BindJSON(&userRequest); err != nil {
-		sendError(c, http.StatusBadRequest, "invalid request")
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		a.sendError(c, op, invalidRequestErr)
 		return
 	}
 
-	userResponse, err := a.authorizer.CreateUser(a.ctx, auth.CreateUserRequest{
+	userResponse, err := a.authorizer.CreateUser(ctx, auth.CreateUserRequest{
 		Username: userRequest.Username,
 		Password: userRequest.Password,
 		Email:    userRequest.Email,
 	})
 
-	switch {
-	case errors.Is(err, auth.UserAlreadyExistsErr):
-		sendError(c, http.StatusBadRequest, "Username or email already exists")
-		return
-	case err != nil:
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed registration")
+	if err != nil {
+		a.sendError(c, op, err, "request", userRequest)
 		return
-
 	}
 
-	if err := a.storage.NewWallet(a.ctx, userResponse.UserId); err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed create new wallet")
+	if err := a.storage.NewWallet(ctx, userResponse.UserId, userRequest.Username); err != nil {
+		a.sendError(c, op, err, "user_id", userResponse.UserId)
 		return
 	}
 
@@ -86,34 +137,60 @@ func (a *App) Register(c *gin.Context) {
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
 // @Router /api/v1/login [post]
 func (a *App) Login(c *gin.Context) {
 	const op = "App Login"
 
+	ctx := c.Request.Context()
+
 	var credentials Credentials
 
-	if err := c.BindJSON(&credentials); err != nil {
-		sendError(c, http.StatusBadRequest, "invalid request")
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		a.sendError(c, op, invalidRequestErr)
 		return
 	}
 
-	token, err := a.authorizer.Login(a.ctx, auth.LoginCredentials{
+	token, err := a.authorizer.Login(ctx, auth.LoginCredentials{
 		Username: credentials.Username,
 		Password: credentials.Password,
 	})
-	switch {
-	case errors.Is(err, auth.InvalidCredentialsErr):
-		sendError(c, http.StatusUnauthorized, "Invalid username or password")
-		return
-	case err != nil:
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed login")
+	if err != nil {
+		a.sendError(c, op, err, "request", credentials)
 		return
+	}
 
+	response := TokenResponseJSON{Token: token.Value}
+
+	claims, claimsErr := tokens.ParseUnverified(token.Value)
+	if claimsErr == nil {
+		a.rememberUsername(ctx, claims.UserId, credentials.Username)
 	}
 
-	c.JSON(http.StatusOK, TokenResponseJSON{token.Value})
+	if a.tokens.CanIssue() {
+		if claimsErr != nil {
+			a.sendError(c, op, claimsErr)
+			return
+		}
+
+		if response.RefreshToken, err = a.issueRefreshToken(ctx, claims.UserId, claims.Roles); err != nil {
+			a.sendError(c, op, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+
+}
+
+// rememberUsername fills in the username of wallets created before usernames
+// were stored. A failure only costs the lookup by username, so the login goes on.
+func (a *App) rememberUsername(ctx context.Context, user, username string) {
+	const op = "App rememberUsername"
 
+	if err := a.storage.SetWalletUsername(ctx, user, username); err != nil {
+		a.logger.Err(ctx, op, err, "user_id", user)
+	}
 }
 
 // @Summary Balance
@@ -122,27 +199,35 @@ func (a *App) Login(c *gin.Context) {
 // @Descriotion user balance
 // @ID user-balance
 // @Produce json
-// @Success 200 {object} storages.Balance
+// @Success 200 {object} map[string]string
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
 // @Router /api/v1/wallet/balance [get]
 func (a *App) Balance(c *gin.Context) {
 	const op = "App Balance"
 
-	user, err := a.authorization(c)
+	ctx := c.Request.Context()
+
+	user, err := currentUser(c)
+	if err != nil {
+		return
+	}
+
+	currencies, err := a.currencies(ctx)
 	if err != nil {
+		a.sendError(c, op, err)
 		return
 	}
 
-	balance, err := a.storage.GetBalance(a.ctx, user)
+	balance, err := a.storage.GetBalance(ctx, user)
 	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to get user balance")
+		a.sendError(c, op, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, balance)
+	c.JSON(http.StatusOK, withEnabledCurrencies(balance, currencies))
 }
 
 // @Summary Deposit
@@ -153,13 +238,17 @@ func (a *App) Balance(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param input body Cash true "desired currency and amount"
+// @Param Idempotency-Key header string false "repeat the request safely: the first response is replayed"
 // @Success 200 {object} NewBalanceResponseJSON
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
+// @Failure 422 {object} ErrResponseJSON
+// @Failure 423 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
 // @Router /api/v1/wallet/deposit [post]
 func (a *App) Deposit(c *gin.Context) {
-	a.DepositWithdrawHandler(c, 1)
+	a.DepositWithdrawHandler(c, decimal.NewFromInt(1))
 }
 
 // @Summary Withdraw
@@ -170,60 +259,134 @@ func (a *App) Deposit(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param input body Cash true "desired currency and amount"
+// @Param Idempotency-Key header string false "repeat the request safely: the first response is replayed"
 // @Success 200 {object} NewBalanceResponseJSON
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
+// @Failure 422 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 423 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
 // @Router /api/v1/wallet/withdraw [post]
 func (a *App) Withdraw(c *gin.Context) {
-	a.DepositWithdrawHandler(c, -1)
+	a.DepositWithdrawHandler(c, decimal.NewFromInt(-1))
 }
 
-func (a *App) DepositWithdrawHandler(c *gin.Context, multiplier float32) {
+func (a *App) DepositWithdrawHandler(c *gin.Context, multiplier decimal.Decimal) {
 	const op = "App Deposit"
 
-	user, err := a.authorization(c)
+	ctx := c.Request.Context()
+
+	user, err := currentUser(c)
 	if err != nil {
 		return
 	}
 
 	var request Cash
 
-	if err = c.BindJSON(&request); err != nil {
-		sendError(c, http.StatusBadRequest, "Invalid amount or currency")
+	if err = bindJSON(c, &request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
 		return
 	}
 
-	if request.Amount < 0 {
-		sendError(c, http.StatusBadRequest, "the amount is less than 0")
+	idem, err := newIdempotency(c, user)
+	if err != nil {
+		a.sendError(c, op, err)
 		return
 	}
 
 	request.Currency = strings.ToUpper(request.Currency)
 
-	balance, err := a.storage.GetBalance(a.ctx, user)
+	currencies, err := a.currencies(ctx)
 	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to get user balance")
+		a.sendError(c, op, err)
 		return
 	}
 
-	_, err = changeBalance(request.Currency, &balance, request.Amount, multiplier)
+	currency, err := lookupCurrency(currencies, request.Currency)
 	if err != nil {
-		sendError(c, http.StatusBadRequest, err.Error())
+		a.sendError(c, op, err)
 		return
 	}
 
-	if err = a.storage.UpdateWallet(a.ctx, user, balance); err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed update balance")
+	if err = validateAmount(currency, request.Amount); err != nil {
+		a.sendError(c, op, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, NewBalanceResponseJSON{
-		Message:    "successful",
-		NewBalance: balance,
+	kind := storages.KindDeposit
+	if multiplier.IsNegative() {
+		kind = storages.KindWithdraw
+	}
+
+	var response NewBalanceResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		balance, err := tx.GetBalanceForUpdate(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if err = idem.check(ctx, tx); err != nil {
+			return err
+		}
+
+		check := a.checkCredit
+		if multiplier.IsNegative() {
+			check = a.checkDebit
+		}
+
+		if err = check(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if multiplier.IsNegative() {
+			if err = a.checkWithdrawal(ctx, tx, user, request.Currency, request.Amount, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		change, err := changeBalance(request.Currency, balance, request.Amount, multiplier)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.UpdateWallet(ctx, user, balance); err != nil {
+			return err
+		}
+
+		err = tx.AddTransactions(ctx, storages.Transaction{
+			UserId:   user,
+			Kind:     kind,
+			Currency: request.Currency,
+			Amount:   change,
+			Balance:  balance[request.Currency],
+		})
+		if err != nil {
+			return err
+		}
+
+		response = NewBalanceResponseJSON{
+			Message:    "successful",
+			NewBalance: withEnabledCurrencies(balance, currencies),
+		}
+
+		return idem.save(ctx, tx, http.StatusOK, response)
 	})
+
+	switch {
+	case errors.Is(err, idempotencyReplayErr):
+		idem.writeReplay(c)
+		return
+	case err != nil:
+		a.sendError(c, op, err)
+		return
+	}
+
+	metrics.Operation(kind, request.Currency, request.Amount.InexactFloat64())
+
+	c.JSON(http.StatusOK, response)
 }
 
 // @Summary Rates
@@ -232,27 +395,30 @@ func (a *App) DepositWithdrawHandler(c *gin.Context, multiplier float32) {
 // @Descriotion rates exchange
 // @ID rates-exchange
 // @Produce json
-// @Success 200 {object} exchange.Rates
+// @Success 200 {object} RatesResponseJSON
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
-// @Failure 500 {object} ErrResponseJSON
+// @Failure 503 {object} ErrResponseJSON
 // @Router /api/v1/exchange/rates [get]
 func (a *App) Rates(c *gin.Context) {
 	const op = "App Rates"
 
-	_, err := a.authorization(c)
+	_, err := currentUser(c)
 	if err != nil {
 		return
 	}
 
-	res, err := a.exchanger.GetExchangeRates(a.ctx)
-	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to retrieve exchange rates")
+	snapshot, ok := a.rates.Last()
+	if !ok {
+		a.sendError(c, op, ratesUnavailableErr)
 		return
 	}
 
-	c.JSONP(http.StatusOK, res)
+	c.JSONP(http.StatusOK, RatesResponseJSON{
+		Rates:      snapshot.Rates.Rates,
+		UpdatedAt:  snapshot.UpdatedAt,
+		AgeSeconds: int64(snapshot.Age(time.Now()).Seconds()),
+	})
 }
 
 // @Summary Exchange
@@ -262,101 +428,178 @@ func (a *App) Rates(c *gin.Context) {
 // @ID exchange-wallet
 // @Accept json
 // @Produce json
-// @Param input body ExchangeRequest true "desired currency and amount"
+// @Param input body ExchangeRequest true "desired currency and amount, or quote_id from /api/v1/exchange/quote"
+// @Param Idempotency-Key header string false "repeat the request safely: the first response is replayed"
 // @Success 200 {object} ExchangeResponseJSON
 // @Failure 400 {object} ErrResponseJSON
 // @Failure 401 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 409 {object} ErrResponseJSON
+// @Failure 410 {object} ErrResponseJSON
+// @Failure 422 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 423 {object} ErrResponseJSON
 // @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Failure 503 {object} ErrResponseJSON
 // @Router /api/v1/exchange [post]
 func (a *App) Exchange(c *gin.Context) {
 	const op = "App Exchange"
 
-	user, err := a.authorization(c)
+	ctx := c.Request.Context()
+
+	user, err := currentUser(c)
 	if err != nil {
 		return
 	}
 
 	var request ExchangeRequest
 
-	if err = c.BindJSON(&request); err != nil {
-		sendError(c, http.StatusBadRequest, "invalid request")
+	if err = bindJSON(c, &request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
 		return
 	}
 
-	if request.Amount < 0 {
-		sendError(c, http.StatusBadRequest, "the amount is less than 0")
+	idem, err := newIdempotency(c, user)
+	if err != nil {
+		a.sendError(c, op, err)
 		return
 	}
 
-	request.FromCurrency, request.ToCurrency = strings.ToUpper(request.FromCurrency), strings.ToUpper(request.ToCurrency)
-
-	balance, err := a.storage.GetBalance(a.ctx, user)
+	currencies, err := a.currencies(ctx)
 	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to get user balance")
+		a.sendError(c, op, err)
 		return
 	}
 
-	var rates exchange.Rates
+	var exchangeAmount, fee decimal.Decimal
 
-	valueFromCache, ok := a.cache.Get("rates")
-	if !ok {
-		rates, err = a.exchanger.GetExchangeRates(a.ctx)
-		if err != nil {
-			a.logger.Err(op, err)
-			sendError(c, http.StatusInternalServerError, "Failed to retrieve exchange rates")
+	if request.QuoteId != "" {
+		if !quoteIdPattern.MatchString(request.QuoteId) {
+			a.sendError(c, op, invalidQuoteErr)
 			return
 		}
-		a.cache.Set("rates", rates)
 	} else {
-		rates = valueFromCache.(exchange.Rates)
-	}
+		request.FromCurrency, request.ToCurrency = strings.ToUpper(request.FromCurrency), strings.ToUpper(request.ToCurrency)
 
-	fromCurrencyRate, ok := rates.Rates[request.FromCurrency]
-	if !ok {
-		sendError(c, http.StatusBadRequest, "Insufficient funds or invalid currencies")
-		return
-	}
+		fromCurrency, err := lookupCurrency(currencies, request.FromCurrency)
+		if err != nil {
+			a.sendError(c, op, err)
+			return
+		}
 
-	toCurrencyRate, ok := rates.Rates[request.ToCurrency]
-	if !ok {
-		sendError(c, http.StatusBadRequest, "Insufficient funds or invalid currencies")
-		return
-	}
+		toCurrency, err := lookupCurrency(currencies, request.ToCurrency)
+		if err != nil {
+			a.sendError(c, op, err)
+			return
+		}
 
-	_, err = changeBalance(request.FromCurrency, &balance, request.Amount, -1)
-	if err != nil {
-		sendError(c, http.StatusBadRequest, err.Error())
-		return
-	}
+		if err = validateAmount(fromCurrency, request.Amount); err != nil {
+			a.sendError(c, op, err)
+			return
+		}
 
-	exchangeAmount, err := changeBalance(request.ToCurrency, &balance, request.Amount, fromCurrencyRate/toCurrencyRate)
-	if err != nil {
-		sendError(c, http.StatusBadRequest, err.Error())
-		return
+		_, exchangeAmount, fee, err = a.priceExchange(request.FromCurrency, request.Amount, toCurrency)
+		if err != nil {
+			a.sendError(c, op, err)
+			return
+		}
 	}
 
-	if err = a.storage.UpdateWallet(a.ctx, user, balance); err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed update balance")
+	var response ExchangeResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		balance, err := tx.GetBalanceForUpdate(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if err = idem.check(ctx, tx); err != nil {
+			return err
+		}
+
+		if err = a.checkDebit(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if request.QuoteId != "" {
+			quote, err := a.useQuote(ctx, tx, user, request.QuoteId)
+			if err != nil {
+				return err
+			}
+			request.FromCurrency, request.ToCurrency = quote.FromCurrency, quote.ToCurrency
+			request.Amount, exchangeAmount, fee = quote.Amount, quote.ToAmount, quote.Fee
+		}
+
+		if err = a.checkExchange(ctx, tx, user, request.FromCurrency, request.Amount, time.Now()); err != nil {
+			return err
+		}
+
+		fromChange, err := changeBalance(request.FromCurrency, balance, request.Amount, decimal.NewFromInt(-1))
+		if err != nil {
+			return err
+		}
+
+		toChange, err := changeBalance(request.ToCurrency, balance, exchangeAmount, decimal.NewFromInt(1))
+		if err != nil {
+			return err
+		}
+
+		if err = tx.UpdateWallet(ctx, user, balance); err != nil {
+			return err
+		}
+
+		err = tx.AddTransactions(ctx,
+			storages.Transaction{
+				UserId:   user,
+				Kind:     storages.KindExchange,
+				Currency: request.FromCurrency,
+				Amount:   fromChange,
+				Balance:  balance[request.FromCurrency],
+			},
+			storages.Transaction{
+				UserId:   user,
+				Kind:     storages.KindExchange,
+				Currency: request.ToCurrency,
+				Amount:   toChange,
+				Balance:  balance[request.ToCurrency],
+			})
+		if err != nil {
+			return err
+		}
+
+		if err = a.collectFee(ctx, tx, user, request.ToCurrency, fee); err != nil {
+			return err
+		}
+
+		response = ExchangeResponseJSON{
+			Message:        "Exchange successful",
+			ExchangeAmount: exchangeAmount,
+			Fee:            fee,
+			NewBalance:     withEnabledCurrencies(balance, currencies),
+		}
+
+		return idem.save(ctx, tx, http.StatusOK, response)
+	})
+
+	switch {
+	case errors.Is(err, idempotencyReplayErr):
+		idem.writeReplay(c)
+		return
+	case err != nil:
+		a.sendError(c, op, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ExchangeResponseJSON{
-		Message:        "Exchange successful",
-		ExchangeAmount: exchangeAmount,
-		NewBalance:     balance,
-	})
+	metrics.Operation(storages.KindExchange, request.FromCurrency, request.Amount.InexactFloat64())
 
-}
+	c.JSON(http.StatusOK, response)
 
-func sendError(c *gin.Context, code int, message string) {
-	c.JSON(code, ErrResponseJSON{message})
 }
 
-func (a *App) verifyToken(userId, token string) (bool, error) {
+func (a *App) verifyToken(ctx context.Context, userId, token string) (bool, error) {
 
-	response, err := a.authorizer.VerifyToken(a.ctx, auth.TokenRequest{UserId: userId, Token: token})
+	response, err := a.authorizer.VerifyToken(ctx, auth.TokenRequest{UserId: userId, Token: token})
 
 	return response.Ok, err
 }
@@ -377,89 +620,23 @@ func getTokenFromString(raw string) (string, error) {
 	return result[0], nil
 }
 
-func (a *App) authorization(c *gin.Context) (string, error) {
-	const op = "App authorization"
-
-	authStr := c.GetHeader("Authorization")
-
-	if authStr == "" {
-		sendError(c, http.StatusUnauthorized, "Access deny")
-		return "", fmt.Errorf("%s: %s", op, "Access deny")
-	}
-
-	token, err := getTokenFromString(authStr)
-	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed token processing")
-		return "", fmt.Errorf("%s: %w", op, err)
-	}
-
-	jwtParser, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
-	if err != nil {
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to parse token")
-		return "", fmt.Errorf("%s: %w", op, err)
-	}
-
-	claims, ok := jwtParser.Claims.(jwt.MapClaims)
-	if !ok {
-		sendError(c, http.StatusBadRequest, "payload is absent")
-		return "", fmt.Errorf("payload is absent")
-	}
-
-	userId := fmt.Sprint(claims["id"])
-	if userId == "" {
-		sendError(c, http.StatusBadRequest, "invalid token")
-		return "", fmt.Errorf("invalid token")
-	}
-
-	ok, err = a.verifyToken(userId, token)
+func adder(before, amount, multiplier decimal.Decimal) (decimal.Decimal, error) {
+	change := amount.Mul(multiplier)
 
-	switch {
-	case errors.Is(err, auth.InvalidCredentialsErr):
-		sendError(c, http.StatusBadRequest, "Invalid token")
-		return "", fmt.Errorf("%s: %s", op, "Invalid token")
-	case err != nil:
-		a.logger.Err(op, err)
-		sendError(c, http.StatusInternalServerError, "Failed to verify token")
-		return "", fmt.Errorf("%s: %w", op, err)
-	case !ok:
-		sendError(c, http.StatusUnauthorized, "Access deny")
-		return "", fmt.Errorf("%s: %s", op, "Access deny")
-	default:
-		return userId, nil
+	if change.IsNegative() && before.LessThan(change.Neg()) {
+		return decimal.Zero, insufficientFundsErr
 	}
 
+	return change, nil
 }
 
-func adder(before, amount, multiplier float32) (float32, error) {
-	if multiplier < 0 && before < amount*(-multiplier) {
-		return 0, fmt.Errorf("insufficient funds or invalid amount")
+func changeBalance(currency string, wallet storages.Balance, amount, multiplier decimal.Decimal) (decimal.Decimal, error) {
+	change, err := adder(wallet[currency], amount, multiplier)
+	if err != nil {
+		return decimal.Zero, err
 	}
 
-	return amount * multiplier, nil
-}
-
-func changeBalance(currency string, wallet *storages.Balance, amount, multiplier float32) (float32, error) {
-	var (
-		change float32
-		err    error
-	)
-
-	switch currency {
-	case "USD":
-		change, err = adder(wallet.USD, amount, multiplier)
-		wallet.USD += change
+	wallet[currency] = wallet[currency].Add(change)
 
-	case "RUB":
-		change, err = adder(wallet.RUB, amount, multiplier)
-		wallet.RUB += change
-	case "EUR":
-		change, err = adder(wallet.EUR, amount, multiplier)
-		wallet.EUR += change
-
-	default:
-		err = fmt.Errorf("unknown currency")
-	}
-	return change, err
+	return change, nil
 }
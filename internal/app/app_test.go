@@ -1,6 +1,36 @@
 package app
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	in_mem "gw-currency-wallet/internal/cache/in-mem"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/grpcClient"
+	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/internal/ratelimit"
+	ratelimit_in_mem "gw-currency-wallet/internal/ratelimit/in-mem"
+	"gw-currency-wallet/internal/rates"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/tokens"
+	"gw-currency-wallet/pkg/logs"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func Test_getTokenFromString(t *testing.T) {
 	type args struct {
@@ -47,14 +77,14 @@ func Test_getTokenFromString(t *testing.T) {
 
 func Test_adder(t *testing.T) {
 	type args struct {
-		before     float32
-		amount     float32
-		multiplier float32
+		before     float64
+		amount     float64
+		multiplier float64
 	}
 	tests := []struct {
 		name    string
 		args    args
-		want    float32
+		want    float64
 		wantErr bool
 	}{
 		{
@@ -120,14 +150,1739 @@ func Test_adder(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := adder(tt.args.before, tt.args.amount, tt.args.multiplier)
+			got, err := adder(decimal.NewFromFloat(tt.args.before), decimal.NewFromFloat(tt.args.amount), decimal.NewFromFloat(tt.args.multiplier))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("adder() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
+			if !got.Equal(decimal.NewFromFloat(tt.want)) {
 				t.Errorf("adder() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_decodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:    "курсор из encodeCursor",
+			cursor:  encodeCursor(42),
+			want:    42,
+			wantErr: false,
+		},
+		{
+			name:    "не base64",
+			cursor:  "%%%",
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "не число",
+			cursor:  "YWJj",
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "неположительный id",
+			cursor:  encodeCursor(0),
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("decodeCursor() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		endOfPeriod bool
+		want        time.Time
+		wantErr     bool
+	}{
+		{
+			name:  "RFC3339",
+			value: "2025-01-10T12:30:00Z",
+			want:  time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "дата как начало периода",
+			value: "2025-01-10",
+			want:  time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "дата как конец периода включается целиком",
+			value:       "2025-01-10",
+			endOfPeriod: true,
+			want:        time.Date(2025, 1, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "неверный формат",
+			value:   "10.01.2025",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.value, tt.endOfPeriod)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_changeBalance_noDrift(t *testing.T) {
+	wallet := storages.Balance{}
+
+	cent := decimal.RequireFromString("0.01")
+	for i := 0; i < 10000; i++ {
+		if _, err := changeBalance("USD", wallet, cent, decimal.NewFromInt(1)); err != nil {
+			t.Fatalf("changeBalance() error = %v", err)
+		}
+	}
+
+	if want := decimal.NewFromInt(100); !wallet["USD"].Equal(want) {
+		t.Errorf("balance after 10000 deposits of 0.01 = %v, want %v", wallet["USD"], want)
+	}
+
+	big := decimal.RequireFromString("16777217.01")
+	if _, err := changeBalance("RUB", wallet, big, decimal.NewFromInt(1)); err != nil {
+		t.Fatalf("changeBalance() error = %v", err)
+	}
+
+	if !wallet["RUB"].Equal(big) {
+		t.Errorf("balance = %v, want %v", wallet["RUB"], big)
+	}
+}
+
+func Test_lookupCurrency(t *testing.T) {
+	currencies := map[string]storages.Currency{
+		"USD": {Code: "USD", MinorUnits: 2, Enabled: true},
+		"KZT": {Code: "KZT", MinorUnits: 2, Enabled: false},
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{
+			name:    "включенная валюта",
+			code:    "USD",
+			wantErr: nil,
+		},
+		{
+			name:    "выключенная валюта",
+			code:    "KZT",
+			wantErr: unknownCurrencyErr,
+		},
+		{
+			name:    "отсутствующая валюта",
+			code:    "XXX",
+			wantErr: unknownCurrencyErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupCurrency(currencies, tt.code)
+			if err != tt.wantErr {
+				t.Errorf("lookupCurrency() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Code != tt.code {
+				t.Errorf("lookupCurrency() got = %v, want %v", got.Code, tt.code)
+			}
+		})
+	}
+}
+
+func Test_validateAmount(t *testing.T) {
+	usd := storages.Currency{Code: "USD", MinorUnits: 2, Enabled: true}
+	jpy := storages.Currency{Code: "JPY", MinorUnits: 0, Enabled: true}
+
+	tests := []struct {
+		name     string
+		currency storages.Currency
+		amount   string
+		wantErr  error
+	}{
+		{
+			name:     "центы",
+			currency: usd,
+			amount:   "100.01",
+			wantErr:  nil,
+		},
+		{
+			name:     "лишние знаки после запятой",
+			currency: usd,
+			amount:   "0.001",
+			wantErr:  amountPrecisionErr,
+		},
+		{
+			name:     "незначащие нули",
+			currency: usd,
+			amount:   "1.500",
+			wantErr:  nil,
+		},
+		{
+			name:     "дробная сумма в валюте без минимальных единиц",
+			currency: jpy,
+			amount:   "1.5",
+			wantErr:  amountPrecisionErr,
+		},
+		{
+			name:     "ноль",
+			currency: usd,
+			amount:   "0",
+			wantErr:  invalidAmountErr,
+		},
+		{
+			name:     "отрицательная сумма",
+			currency: usd,
+			amount:   "-1",
+			wantErr:  invalidAmountErr,
+		},
+		{
+			name:     "18 цифр целой части",
+			currency: usd,
+			amount:   "999999999999999999.99",
+			wantErr:  nil,
+		},
+		{
+			name:     "больше 18 цифр целой части",
+			currency: usd,
+			amount:   "1000000000000000000",
+			wantErr:  amountTooLargeErr,
+		},
+		{
+			name:     "огромный показатель степени",
+			currency: usd,
+			amount:   "1e9999999",
+			wantErr:  amountTooLargeErr,
+		},
+		{
+			name:     "огромный отрицательный показатель степени",
+			currency: usd,
+			amount:   "1e-9999999",
+			wantErr:  amountPrecisionErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAmount(tt.currency, decimal.RequireFromString(tt.amount))
+			if err != tt.wantErr {
+				t.Errorf("validateAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_convert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		fromRate string
+		toRate   string
+		to       storages.Currency
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "округление вниз до копеек",
+			amount:   "10",
+			fromRate: "1",
+			toRate:   "0.011",
+			to:       storages.Currency{Code: "RUB", MinorUnits: 2},
+			want:     "909.09",
+			wantErr:  nil,
+		},
+		{
+			name:     "целый результат",
+			amount:   "100",
+			fromRate: "0.011",
+			toRate:   "1",
+			to:       storages.Currency{Code: "USD", MinorUnits: 2},
+			want:     "1.1",
+			wantErr:  nil,
+		},
+		{
+			name:     "округление до валюты без минимальных единиц",
+			amount:   "10",
+			fromRate: "1",
+			toRate:   "0.0064",
+			to:       storages.Currency{Code: "JPY", MinorUnits: 0},
+			want:     "1562",
+			wantErr:  nil,
+		},
+		{
+			name:     "сумма меньше минимальной единицы",
+			amount:   "0.01",
+			fromRate: "0.011",
+			toRate:   "1",
+			to:       storages.Currency{Code: "USD", MinorUnits: 2},
+			want:     "0",
+			wantErr:  amountTooSmallErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convert(decimal.RequireFromString(tt.amount), decimal.RequireFromString(tt.fromRate), decimal.RequireFromString(tt.toRate), tt.to)
+			if err != tt.wantErr {
+				t.Errorf("convert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("convert() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type idempotencyTx struct {
+	storages.Tx
+	record storages.IdempotencyRecord
+	found  bool
+}
+
+func (t idempotencyTx) GetIdempotencyRecord(_ context.Context, _, _ string) (storages.IdempotencyRecord, bool, error) {
+	return t.record, t.found, nil
+}
+
+func Test_idempotency_check(t *testing.T) {
+	tests := []struct {
+		name    string
+		idem    *idempotency
+		tx      idempotencyTx
+		wantErr error
+	}{
+		{
+			name:    "без заголовка",
+			idem:    nil,
+			tx:      idempotencyTx{},
+			wantErr: nil,
+		},
+		{
+			name:    "первый запрос",
+			idem:    &idempotency{user: "1", key: "k", hash: "a"},
+			tx:      idempotencyTx{found: false},
+			wantErr: nil,
+		},
+		{
+			name:    "повтор с тем же телом",
+			idem:    &idempotency{user: "1", key: "k", hash: "a"},
+			tx:      idempotencyTx{found: true, record: storages.IdempotencyRecord{RequestHash: "a", CreatedAt: time.Now()}},
+			wantErr: idempotencyReplayErr,
+		},
+		{
+			name:    "повтор с другим телом",
+			idem:    &idempotency{user: "1", key: "k", hash: "b"},
+			tx:      idempotencyTx{found: true, record: storages.IdempotencyRecord{RequestHash: "a", CreatedAt: time.Now()}},
+			wantErr: idempotencyMismatchErr,
+		},
+		{
+			name:    "истекший ключ",
+			idem:    &idempotency{user: "1", key: "k", hash: "b"},
+			tx:      idempotencyTx{found: true, record: storages.IdempotencyRecord{RequestHash: "a", CreatedAt: time.Now().Add(-2 * idempotencyKeyLifetime)}},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.idem.check(context.Background(), tt.tx); err != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_checkQuote(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	quote := storages.Quote{UserId: "user", ExpiresAt: now.Add(time.Second)}
+
+	used := quote
+	used.UsedAt = now.Add(-time.Second)
+
+	tests := []struct {
+		name    string
+		quote   storages.Quote
+		user    string
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "действующая котировка",
+			quote:   quote,
+			user:    "user",
+			now:     now,
+			wantErr: nil,
+		},
+		{
+			name:    "чужая котировка",
+			quote:   quote,
+			user:    "other",
+			now:     now,
+			wantErr: storages.QuoteNotFoundErr,
+		},
+		{
+			name:    "котировка уже использована",
+			quote:   used,
+			user:    "user",
+			now:     now,
+			wantErr: quoteUsedErr,
+		},
+		{
+			name:    "котировка истекла",
+			quote:   quote,
+			user:    "user",
+			now:     quote.ExpiresAt,
+			wantErr: quoteExpiredErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkQuote(tt.quote, tt.user, tt.now); err != tt.wantErr {
+				t.Errorf("checkQuote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_fees_apply(t *testing.T) {
+	f, err := newFees(config.ExchangeConfig{
+		Spread:      "1",
+		PairSpreads: "usd/eur=0.5",
+		MinFee:      "EUR=0.10,RUB=10",
+		FeeFree:     "USD=10",
+	})
+	if err != nil {
+		t.Fatalf("newFees() error = %v", err)
+	}
+
+	eur := storages.Currency{Code: "EUR", MinorUnits: 2, Enabled: true}
+	rub := storages.Currency{Code: "RUB", MinorUnits: 2, Enabled: true}
+
+	tests := []struct {
+		name      string
+		from      string
+		amount    string
+		to        storages.Currency
+		converted string
+		wantNet   string
+		wantFee   string
+		wantErr   error
+	}{
+		{
+			name:      "спред валютной пары",
+			from:      "USD",
+			amount:    "100",
+			to:        eur,
+			converted: "90",
+			wantNet:   "89.55",
+			wantFee:   "0.45",
+		},
+		{
+			name:      "общий спред с округлением вверх",
+			from:      "EUR",
+			amount:    "1000",
+			to:        rub,
+			converted: "100000.01",
+			wantNet:   "99000",
+			wantFee:   "1000.01",
+		},
+		{
+			name:      "минимальная комиссия",
+			from:      "EUR",
+			amount:    "5",
+			to:        rub,
+			converted: "500",
+			wantNet:   "490",
+			wantFee:   "10",
+		},
+		{
+			name:      "сумма без комиссии",
+			from:      "USD",
+			amount:    "10",
+			to:        eur,
+			converted: "9",
+			wantNet:   "9",
+			wantFee:   "0",
+		},
+		{
+			name:      "комиссия больше суммы",
+			from:      "RUB",
+			amount:    "5",
+			to:        eur,
+			converted: "0.05",
+			wantErr:   amountTooSmallErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			net, fee, err := f.apply(tt.from, decimal.RequireFromString(tt.amount), tt.to, decimal.RequireFromString(tt.converted))
+			if err != tt.wantErr {
+				t.Errorf("apply() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !net.Equal(decimal.RequireFromString(tt.wantNet)) {
+				t.Errorf("apply() net = %v, want %v", net, tt.wantNet)
+			}
+			if !fee.Equal(decimal.RequireFromString(tt.wantFee)) {
+				t.Errorf("apply() fee = %v, want %v", fee, tt.wantFee)
+			}
+		})
+	}
+}
+
+type revocationStorage struct {
+	storages.Storage
+	revoked map[string]bool
+}
+
+func (s revocationStorage) IsTokenRevoked(_ context.Context, tokenId string) (bool, error) {
+	return s.revoked[tokenId], nil
+}
+
+func TestApp_Authenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verifier, err := tokens.New(config.JWTConfig{SharedKey: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("tokens.New() error = %v", err)
+	}
+	a := &App{
+		tokens:  verifier,
+		cache:   in_mem.New(time.Minute, in_mem.Hooks{}),
+		storage: revocationStorage{revoked: map[string]bool{"t-revoked": true}},
+	}
+
+	router := gin.New()
+	router.GET("/protected", a.Authenticate, func(c *gin.Context) {
+		principal, _ := PrincipalFromContext(c)
+		c.JSON(http.StatusOK, principal)
+	})
+	router.GET("/unprotected", func(c *gin.Context) {
+		if _, err := currentUser(c); err != nil {
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	sign := func(key string, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantPrincipal Principal
+	}{
+		{
+			name:          "валидный токен",
+			path:          "/protected",
+			authorization: sign("secret", jwt.MapClaims{"id": "7", "exp": exp, "jti": "t-1", "roles": []string{"admin"}}),
+			wantStatus:    http.StatusOK,
+			wantPrincipal: Principal{UserId: "7", Roles: []string{"admin"}, TokenId: "t-1", ExpiresAt: time.Unix(exp, 0)},
+		},
+		{
+			name:          "отозванный токен",
+			path:          "/protected",
+			authorization: sign("secret", jwt.MapClaims{"id": "7", "exp": exp, "jti": "t-revoked"}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:       "нет заголовка",
+			path:       "/protected",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "чужая подпись",
+			path:          "/protected",
+			authorization: sign("other", jwt.MapClaims{"id": "7", "exp": exp}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "маршрут без middleware",
+			path:          "/unprotected",
+			authorization: sign("secret", jwt.MapClaims{"id": "7", "exp": exp}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK || tt.path != "/protected" {
+				return
+			}
+
+			var got Principal
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.ExpiresAt.Equal(tt.wantPrincipal.ExpiresAt) {
+				t.Errorf("principal ExpiresAt = %v, want %v", got.ExpiresAt, tt.wantPrincipal.ExpiresAt)
+			}
+			got.ExpiresAt = tt.wantPrincipal.ExpiresAt
+			if !reflect.DeepEqual(got, tt.wantPrincipal) {
+				t.Errorf("principal = %+v, want %+v", got, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestApp_Authenticate_revokedAfterCaching(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verifier, err := tokens.New(config.JWTConfig{SharedKey: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("tokens.New() error = %v", err)
+	}
+	storage := revocationStorage{revoked: map[string]bool{}}
+	a := &App{tokens: verifier, cache: in_mem.New(time.Minute, in_mem.Hooks{}), storage: storage}
+	a.cfg.JWT.CacheTTL = 60
+
+	router := gin.New()
+	router.GET("/protected", a.Authenticate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "7", "exp": time.Now().Add(time.Hour).Unix(), "jti": "t-1"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func() int {
+		request := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if got := send(); got != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got, http.StatusOK)
+	}
+	if _, ok := a.cache.Get(tokenCacheKey(token)); !ok {
+		t.Fatalf("token was not cached")
+	}
+
+	storage.revoked["t-1"] = true
+
+	if got := send(); got != http.StatusUnauthorized {
+		t.Errorf("status after revocation = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestApp_rememberToken(t *testing.T) {
+	a := &App{cache: in_mem.New(time.Minute, in_mem.Hooks{})}
+	a.cfg.JWT.CacheTTL = 60
+	principal := Principal{UserId: "7"}
+
+	a.rememberToken("expired", principal, time.Now().Add(-time.Second))
+	if _, ok := a.cache.Get(tokenCacheKey("expired")); ok {
+		t.Errorf("expired token was cached")
+	}
+
+	a.rememberToken("short", principal, time.Now().Add(50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := a.cache.Get(tokenCacheKey("short")); ok {
+		t.Errorf("token was cached after its exp")
+	}
+
+	a.rememberToken("valid", principal, time.Time{})
+	if _, ok := a.cache.Get(tokenCacheKey("valid")); !ok {
+		t.Errorf("token without exp was not cached")
+	}
+
+	a.forgetToken("valid")
+	if _, ok := a.cache.Get(tokenCacheKey("valid")); ok {
+		t.Errorf("token was cached after forgetToken")
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		roles      []string
+		wantStatus int
+	}{
+		{
+			name:       "админ на маршруте поддержки",
+			principal:  &Principal{UserId: "1", Roles: []string{RoleAdmin}},
+			roles:      []string{RoleSupport, RoleAdmin},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "поддержка на маршруте админа",
+			principal:  &Principal{UserId: "1", Roles: []string{RoleSupport}},
+			roles:      []string{RoleAdmin},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "обычный пользователь",
+			principal:  &Principal{UserId: "1", Roles: []string{RoleUser}},
+			roles:      []string{RoleSupport, RoleAdmin},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "без аутентификации",
+			roles:      []string{RoleAdmin},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin", func(c *gin.Context) {
+				if tt.principal != nil {
+					c.Set(principalKey, *tt.principal)
+				}
+				requireRole(c, tt.roles...)
+			}, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_walletStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantDebit  error
+		wantCredit error
+	}{
+		{name: "активный", status: storages.WalletActive},
+		{name: "замороженный", status: storages.WalletFrozen, wantDebit: walletFrozenErr},
+		{name: "закрытый", status: storages.WalletClosed, wantDebit: walletClosedErr, wantCredit: walletClosedErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := debitAllowed(tt.status); !errors.Is(err, tt.wantDebit) {
+				t.Errorf("debitAllowed() error = %v, want %v", err, tt.wantDebit)
+			}
+			if err := creditAllowed(tt.status); !errors.Is(err, tt.wantCredit) {
+				t.Errorf("creditAllowed() error = %v, want %v", err, tt.wantCredit)
+			}
+		})
+	}
+}
+
+func Test_parseTierMap(t *testing.T) {
+	got, err := parseTierMap("unverified:USD=1000,rub=100000; verified:USD=10000")
+	if err != nil {
+		t.Fatalf("parseTierMap() error = %v", err)
+	}
+	if !got["unverified"]["RUB"].Equal(decimal.NewFromInt(100000)) || !got["verified"]["USD"].Equal(decimal.NewFromInt(10000)) {
+		t.Errorf("parseTierMap() = %v", got)
+	}
+	if _, ok := got["verified"]["RUB"]; ok {
+		t.Errorf("parseTierMap() added a limit that was not configured")
+	}
+
+	for _, value := range []string{"USD=1000", ":USD=1000", "unverified:USD"} {
+		if _, err := parseTierMap(value); err == nil {
+			t.Errorf("parseTierMap(%q) expected error", value)
+		}
+	}
+}
+
+func Test_checkRemaining(t *testing.T) {
+	resetsAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		used          string
+		amount        string
+		wantRemaining string
+		wantErr       bool
+	}{
+		{name: "в пределах лимита", used: "700", amount: "300"},
+		{name: "превышение", used: "700", amount: "300.01", wantRemaining: "300", wantErr: true},
+		{name: "лимит уже исчерпан", used: "1200", amount: "1", wantRemaining: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRemaining(limitWithdrawDaily, "USD", decimal.NewFromInt(1000), decimal.RequireFromString(tt.used), decimal.RequireFromString(tt.amount), resetsAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRemaining() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			var limitErr *limitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("checkRemaining() error = %T, want *limitError", err)
+			}
+			if !limitErr.Remaining.Equal(decimal.RequireFromString(tt.wantRemaining)) || !limitErr.ResetsAt.Equal(resetsAt) {
+				t.Errorf("checkRemaining() = %+v", limitErr)
+			}
+		})
+	}
+}
+
+func TestApp_sendError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	a := &App{logger: logs.New(io.Discard, logs.FormatText, slog.LevelInfo)}
+
+	tests := []struct {
+		name        string
+		err         error
+		accept      string
+		wantStatus  int
+		wantCode    ErrorCode
+		wantDetails bool
+	}{
+		{name: "ошибка из каталога", err: fmt.Errorf("App Withdraw: %w", insufficientFundsErr), wantStatus: http.StatusBadRequest, wantCode: CodeInsufficientFunds},
+		{name: "превышен лимит", err: checkRemaining(limitWithdrawDaily, "USD", decimal.NewFromInt(10), decimal.NewFromInt(10), decimal.NewFromInt(1), time.Now()), wantStatus: http.StatusForbidden, wantCode: CodeLimitExceeded, wantDetails: true},
+		{name: "ошибка проверки запроса", err: badRequest(errors.New("invalid cursor")), wantStatus: http.StatusBadRequest, wantCode: CodeInvalidRequest},
+		{name: "problem+json", err: walletFrozenErr, accept: "application/problem+json", wantStatus: http.StatusLocked, wantCode: CodeWalletFrozen},
+		{name: "таймаут запроса к БД", err: fmt.Errorf("PSQL GetBalance: %w", context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout, wantCode: CodeUpstreamTimeout},
+		{name: "таймаут gRPC", err: grpcClient.WrapErr(status.Error(codes.DeadlineExceeded, "deadline")), wantStatus: http.StatusGatewayTimeout, wantCode: CodeUpstreamTimeout},
+		{name: "сервис недоступен", err: grpcClient.WrapErr(status.Error(codes.Unavailable, "unavailable")), wantStatus: http.StatusServiceUnavailable, wantCode: CodeUpstreamUnavailable},
+		{name: "клиент отключился", err: grpcClient.WrapErr(status.Error(codes.Canceled, "canceled")), wantStatus: statusClientClosedRequest},
+		{name: "прочая ошибка", err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantCode: CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request = c.Request.WithContext(logs.ContextWithRequestId(c.Request.Context(), "req-1"))
+			c.Request.Header.Set("Accept", tt.accept)
+
+			a.sendError(c, "test", tt.err)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var got ProblemJSON
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Code != tt.wantCode || got.RequestId != "req-1" || (got.Details != nil) != tt.wantDetails {
+				t.Errorf("body = %s", recorder.Body.String())
+			}
+			if tt.accept != "" && (got.Status != tt.wantStatus || recorder.Header().Get("Content-Type") != problemContentType) {
+				t.Errorf("problem = %s, Content-Type = %s", recorder.Body.String(), recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestApp_Login_invalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	a := &App{logger: logs.New(io.Discard, logs.FormatText, slog.LevelInfo)}
+
+	router := gin.New()
+	router.POST("/register", a.Register)
+	router.POST("/login", a.Login)
+
+	for _, path := range []string{"/register", "/login"} {
+		t.Run(path, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{"))
+			request.Header.Set("Accept", problemContentType)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			var got ProblemJSON
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			// Result holds the headers as they were when the status was written.
+			contentType := recorder.Result().Header.Get("Content-Type")
+			if recorder.Code != http.StatusBadRequest || got.Code != CodeInvalidRequest || contentType != problemContentType {
+				t.Errorf("status = %d, Content-Type = %s, body = %s", recorder.Code, contentType, recorder.Body.String())
+			}
+		})
+	}
+}
+
+// refreshStorage keeps a single refresh token and the tokens saved in its place.
+type refreshStorage struct {
+	storages.Storage
+	token storages.RefreshToken
+	saved []storages.RefreshToken
+}
+
+func (s *refreshStorage) UseRefreshToken(_ context.Context, hash string) (storages.RefreshToken, error) {
+	if hash != s.token.Hash {
+		return storages.RefreshToken{}, storages.RefreshTokenNotFoundErr
+	}
+	return s.token, nil
+}
+
+func (s *refreshStorage) SaveRefreshToken(_ context.Context, token storages.RefreshToken) error {
+	s.saved = append(s.saved, token)
+	return nil
+}
+
+func TestApp_Refresh_privilegedRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verifier, err := tokens.New(config.JWTConfig{SharedKey: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("tokens.New() error = %v", err)
+	}
+	storage := &refreshStorage{token: storages.RefreshToken{Hash: tokens.Hash("refresh"), UserId: "7", Roles: []string{RoleUser, RoleAdmin}}}
+	a := &App{tokens: verifier, storage: storage}
+	a.cfg.JWT.AccessTTL = 900
+
+	router := gin.New()
+	router.POST("/token/refresh", a.Refresh)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token": "refresh"}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response TokenResponseJSON
+	if err = json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.ParseUnverified(response.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{RoleUser}
+	if !reflect.DeepEqual(claims.Roles, want) {
+		t.Errorf("access token roles = %v, want %v", claims.Roles, want)
+	}
+	if len(storage.saved) != 1 || !reflect.DeepEqual(storage.saved[0].Roles, want) {
+		t.Errorf("saved refresh tokens = %+v", storage.saved)
+	}
+}
+
+// walletStorage keeps wallets in memory. Its transactions run one at a time and
+// remember the order in which the wallets were locked.
+type walletStorage struct {
+	storages.Storage
+	mu           sync.Mutex
+	wallets      map[string]storages.Balance
+	statuses     map[string]string
+	audit        []storages.AuditRecord
+	usernames    map[string]string
+	idempotency  map[string]storages.IdempotencyRecord
+	quotes       map[string]storages.Quote
+	locks        []string
+	transactions []storages.Transaction
+}
+
+func newWalletStorage(wallets map[string]storages.Balance, usernames map[string]string) *walletStorage {
+	return &walletStorage{wallets: wallets, usernames: usernames, idempotency: map[string]storages.IdempotencyRecord{}}
+}
+
+func (s *walletStorage) GetCurrencies(_ context.Context) ([]storages.Currency, error) {
+	return []storages.Currency{{Code: "USD", MinorUnits: 2, Enabled: true}, {Code: "EUR", MinorUnits: 2, Enabled: true}}, nil
+}
+
+func (s *walletStorage) GetUserIdByUsername(_ context.Context, username string) (string, error) {
+	user, ok := s.usernames[username]
+	if !ok {
+		return "", storages.WalletNotFoundErr
+	}
+	return user, nil
+}
+
+func (s *walletStorage) GetWallet(_ context.Context, user string) (storages.Wallet, error) {
+	balance, ok := s.wallets[user]
+	if !ok {
+		return storages.Wallet{}, storages.WalletNotFoundErr
+	}
+
+	status, ok := s.statuses[user]
+	if !ok {
+		status = storages.WalletActive
+	}
+
+	return storages.Wallet{UserId: user, Status: status, Tier: storages.DefaultTier, Balance: balance}, nil
+}
+
+func (s *walletStorage) GetTransactions(_ context.Context, filter storages.TransactionFilter) ([]storages.Transaction, error) {
+	var result []storages.Transaction
+	for _, transaction := range s.transactions {
+		if transaction.UserId == filter.UserId {
+			result = append(result, transaction)
+		}
+	}
+	return result, nil
+}
+
+func (s *walletStorage) AddAuditRecord(_ context.Context, record storages.AuditRecord) error {
+	s.audit = append(s.audit, record)
+	return nil
+}
+
+func (s *walletStorage) Transaction(ctx context.Context, fn func(context.Context, storages.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fn(ctx, walletTx{s: s})
+}
+
+type walletTx struct {
+	storages.Tx
+	s *walletStorage
+}
+
+func (t walletTx) GetBalanceForUpdate(_ context.Context, user string) (storages.Balance, error) {
+	t.s.locks = append(t.s.locks, user)
+
+	balance, ok := t.s.wallets[user]
+	if !ok {
+		return nil, storages.WalletNotFoundErr
+	}
+
+	result := storages.Balance{}
+	for currency, amount := range balance {
+		result[currency] = amount
+	}
+	return result, nil
+}
+
+func (t walletTx) UpdateWallet(_ context.Context, user string, balance storages.Balance) error {
+	for currency, amount := range balance {
+		t.s.wallets[user][currency] = amount
+	}
+	return nil
+}
+
+func (t walletTx) AddTransactions(_ context.Context, transactions ...storages.Transaction) error {
+	t.s.transactions = append(t.s.transactions, transactions...)
+	return nil
+}
+
+func (t walletTx) GetWalletStatus(_ context.Context, user string) (string, error) {
+	if status, ok := t.s.statuses[user]; ok {
+		return status, nil
+	}
+	return storages.WalletActive, nil
+}
+
+func (t walletTx) SetWalletStatus(_ context.Context, user, status string) error {
+	if t.s.statuses == nil {
+		t.s.statuses = map[string]string{}
+	}
+	t.s.statuses[user] = status
+	return nil
+}
+
+func (t walletTx) GetWalletTier(_ context.Context, _ string) (string, error) {
+	return storages.DefaultTier, nil
+}
+
+func (t walletTx) AddAuditRecord(_ context.Context, record storages.AuditRecord) error {
+	t.s.audit = append(t.s.audit, record)
+	return nil
+}
+
+func (t walletTx) GetIdempotencyRecord(_ context.Context, user, key string) (storages.IdempotencyRecord, bool, error) {
+	record, ok := t.s.idempotency[user+"/"+key]
+	return record, ok, nil
+}
+
+func (t walletTx) SaveIdempotencyRecord(_ context.Context, record storages.IdempotencyRecord) error {
+	record.CreatedAt = time.Now()
+	t.s.idempotency[record.UserId+"/"+record.Key] = record
+	return nil
+}
+
+func (t walletTx) GetQuoteForUpdate(_ context.Context, id string) (storages.Quote, error) {
+	quote, ok := t.s.quotes[id]
+	if !ok {
+		return storages.Quote{}, storages.QuoteNotFoundErr
+	}
+	return quote, nil
+}
+
+func (t walletTx) MarkQuoteUsed(_ context.Context, id string) error {
+	quote := t.s.quotes[id]
+	quote.UsedAt = time.Now()
+	t.s.quotes[id] = quote
+	return nil
+}
+
+func newWalletRouter(a *App, user string) *gin.Engine {
+	router := gin.New()
+	wallet := router.Group("/", func(c *gin.Context) {
+		c.Set(principalKey, Principal{UserId: user})
+	})
+	wallet.POST("/deposit", a.Deposit)
+	wallet.POST("/withdraw", a.Withdraw)
+	wallet.POST("/exchange", a.Exchange)
+	wallet.POST("/transfer", a.Transfer)
+	return router
+}
+
+type ratesExchanger struct {
+	exchange.Exchanger
+	rates map[string]decimal.Decimal
+}
+
+func (e ratesExchanger) GetExchangeRates(context.Context) (exchange.Rates, error) {
+	return exchange.Rates{Rates: e.rates}, nil
+}
+
+// newFreshRates returns a provider that already holds the rates. Run with a
+// cancelled context refreshes once and returns.
+func newFreshRates(values map[string]decimal.Decimal) *rates.Provider {
+	provider := rates.New(ratesExchanger{rates: values}, time.Minute, time.Second, time.Minute, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider.Run(ctx)
+
+	return provider
+}
+
+func TestApp_lockWallets(t *testing.T) {
+	for _, users := range [][]string{{"a", "b"}, {"b", "a"}} {
+		storage := newWalletStorage(map[string]storages.Balance{"a": {}, "b": {}}, nil)
+		a := &App{storage: storage}
+
+		err := storage.Transaction(context.Background(), func(ctx context.Context, tx storages.Tx) error {
+			_, err := a.lockWallets(context.Background(), tx, users...)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("lockWallets(%v) error = %v", users, err)
+		}
+		if !reflect.DeepEqual(storage.locks, []string{"a", "b"}) {
+			t.Errorf("lockWallets(%v) locked %v, want [a b]", users, storage.locks)
+		}
+	}
+}
+
+func TestApp_Transfer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		user         string
+		body         string
+		wantStatus   int
+		wantCode     ErrorCode
+		wantBalances map[string]string
+	}{
+		{
+			name:         "перевод по идентификатору",
+			user:         "a",
+			body:         `{"to_user_id": "b", "currency": "usd", "amount": "30"}`,
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]string{"a": "70", "b": "30"},
+		},
+		{
+			name:         "перевод по имени пользователя",
+			user:         "a",
+			body:         `{"to_username": "bob", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]string{"a": "70", "b": "30"},
+		},
+		{
+			name:         "перевод самому себе",
+			user:         "a",
+			body:         `{"to_user_id": "a", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     CodeSelfTransfer,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+		{
+			name:         "недостаточно средств",
+			user:         "a",
+			body:         `{"to_user_id": "b", "currency": "USD", "amount": "100.01"}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     CodeInsufficientFunds,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+		{
+			name:         "получатель не найден",
+			user:         "a",
+			body:         `{"to_user_id": "c", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusNotFound,
+			wantCode:     CodeRecipientNotFound,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+		{
+			name:         "неизвестное имя получателя",
+			user:         "a",
+			body:         `{"to_username": "carol", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusNotFound,
+			wantCode:     CodeRecipientNotFound,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+		{
+			name:         "нет кошелька отправителя",
+			user:         "c",
+			body:         `{"to_user_id": "b", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusNotFound,
+			wantCode:     CodeWalletNotFound,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+		{
+			name:         "перевод на кошелек комиссий",
+			user:         "a",
+			body:         `{"to_user_id": "house", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusNotFound,
+			wantCode:     CodeRecipientNotFound,
+			wantBalances: map[string]string{"a": "100", "house": "0"},
+		},
+		{
+			name:         "идентификатор и имя вместе",
+			user:         "a",
+			body:         `{"to_user_id": "b", "to_username": "bob", "currency": "USD", "amount": "30"}`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     CodeInvalidRequest,
+			wantBalances: map[string]string{"a": "100", "b": "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{
+				"a":     {"USD": decimal.NewFromInt(100)},
+				"b":     {},
+				"house": {},
+			}, map[string]string{"bob": "b"})
+			a := &App{storage: storage, cache: in_mem.New(time.Minute, in_mem.Hooks{})}
+			a.cfg.Exchange.HouseWallet = "house"
+			recorder := httptest.NewRecorder()
+
+			newWalletRouter(a, tt.user).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/transfer", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantCode != "" {
+				var got ErrResponseJSON
+				if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Code != tt.wantCode {
+					t.Errorf("code = %s, want %s", got.Code, tt.wantCode)
+				}
+			}
+			for user, want := range tt.wantBalances {
+				if got := storage.wallets[user]["USD"]; !got.Equal(decimal.RequireFromString(want)) {
+					t.Errorf("balance of %s = %v, want %v", user, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApp_Transfer_idempotentReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	storage := newWalletStorage(map[string]storages.Balance{
+		"a": {"USD": decimal.NewFromInt(100)},
+		"b": {},
+	}, nil)
+	a := &App{storage: storage, cache: in_mem.New(time.Minute, in_mem.Hooks{})}
+	router := newWalletRouter(a, "a")
+
+	send := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/transfer", strings.NewReader(`{"to_user_id": "b", "currency": "USD", "amount": "30"}`))
+		request.Header.Set(idempotencyKeyHeader, "k-1")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	first := send()
+	second := send()
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status = %d, %d, want %d", first.Code, second.Code, http.StatusOK)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("second response is not a replay")
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("replayed body = %s, want %s", second.Body.String(), first.Body.String())
+	}
+	if got := storage.wallets["a"]["USD"]; !got.Equal(decimal.NewFromInt(70)) {
+		t.Errorf("balance of a = %v, want 70", got)
+	}
+	if len(storage.transactions) != 2 {
+		t.Errorf("ledger has %d records, want 2", len(storage.transactions))
+	}
+}
+
+func TestApp_idempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		otherBody   string
+		wantBalance storages.Balance
+		wantLedger  int
+	}{
+		{
+			name:        "пополнение",
+			path:        "/deposit",
+			body:        `{"currency": "USD", "amount": "30"}`,
+			otherBody:   `{"currency": "USD", "amount": "31"}`,
+			wantBalance: storages.Balance{"USD": decimal.NewFromInt(130)},
+			wantLedger:  1,
+		},
+		{
+			name:        "снятие",
+			path:        "/withdraw",
+			body:        `{"currency": "USD", "amount": "30"}`,
+			otherBody:   `{"currency": "USD", "amount": "31"}`,
+			wantBalance: storages.Balance{"USD": decimal.NewFromInt(70)},
+			wantLedger:  1,
+		},
+		{
+			name:        "обмен",
+			path:        "/exchange",
+			body:        `{"from_currency": "USD", "to_currency": "EUR", "amount": "30"}`,
+			otherBody:   `{"from_currency": "USD", "to_currency": "EUR", "amount": "31"}`,
+			wantBalance: storages.Balance{"USD": decimal.NewFromInt(70), "EUR": decimal.NewFromInt(15)},
+			wantLedger:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{"a": {"USD": decimal.NewFromInt(100)}}, nil)
+			a := &App{
+				storage: storage,
+				cache:   in_mem.New(time.Minute, in_mem.Hooks{}),
+				rates:   newFreshRates(map[string]decimal.Decimal{"USD": decimal.NewFromInt(1), "EUR": decimal.NewFromInt(2)}),
+			}
+			router := newWalletRouter(a, "a")
+
+			send := func(body string) *httptest.ResponseRecorder {
+				request := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+				request.Header.Set(idempotencyKeyHeader, "k-1")
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			first := send(tt.body)
+			second := send(tt.body)
+
+			if first.Code != http.StatusOK || second.Code != http.StatusOK {
+				t.Fatalf("status = %d, %d, want %d, body = %s", first.Code, second.Code, http.StatusOK, first.Body.String())
+			}
+			if second.Header().Get("Idempotent-Replayed") != "true" {
+				t.Errorf("second response is not a replay")
+			}
+			if first.Body.String() != second.Body.String() {
+				t.Errorf("replayed body = %s, want %s", second.Body.String(), first.Body.String())
+			}
+
+			if other := send(tt.otherBody); other.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status with another body = %d, want %d", other.Code, http.StatusUnprocessableEntity)
+			}
+
+			for currency, want := range tt.wantBalance {
+				if got := storage.wallets["a"][currency]; !got.Equal(want) {
+					t.Errorf("balance %s = %v, want %v", currency, got, want)
+				}
+			}
+			if len(storage.transactions) != tt.wantLedger {
+				t.Errorf("ledger has %d records, want %d", len(storage.transactions), tt.wantLedger)
+			}
+		})
+	}
+}
+
+func TestApp_Exchange_quote(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const quoteId = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+	// The rates have moved since the quote: 10 USD buy 5 EUR now, but the quote locked 4.
+	quote := storages.Quote{
+		Id:           quoteId,
+		UserId:       "a",
+		FromCurrency: "USD",
+		ToCurrency:   "EUR",
+		Amount:       decimal.NewFromInt(10),
+		Rate:         decimal.RequireFromString("0.4"),
+		ToAmount:     decimal.NewFromInt(4),
+		ExpiresAt:    time.Now().Add(time.Minute),
+	}
+
+	tests := []struct {
+		name       string
+		quote      func(storages.Quote) storages.Quote
+		wantStatus int
+		wantCode   ErrorCode
+		wantEUR    string
+	}{
+		{
+			name:       "курс зафиксирован котировкой",
+			quote:      func(q storages.Quote) storages.Quote { return q },
+			wantStatus: http.StatusOK,
+			wantEUR:    "4",
+		},
+		{
+			name:       "котировка истекла",
+			quote:      func(q storages.Quote) storages.Quote { q.ExpiresAt = time.Now().Add(-time.Second); return q },
+			wantStatus: http.StatusGone,
+			wantCode:   CodeQuoteExpired,
+			wantEUR:    "0",
+		},
+		{
+			name:       "котировка уже использована",
+			quote:      func(q storages.Quote) storages.Quote { q.UsedAt = time.Now().Add(-time.Second); return q },
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeQuoteUsed,
+			wantEUR:    "0",
+		},
+		{
+			name:       "котировка другого пользователя",
+			quote:      func(q storages.Quote) storages.Quote { q.UserId = "b"; return q },
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeQuoteNotFound,
+			wantEUR:    "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{"a": {"USD": decimal.NewFromInt(100)}}, nil)
+			storage.quotes = map[string]storages.Quote{quoteId: tt.quote(quote)}
+			a := &App{
+				storage: storage,
+				cache:   in_mem.New(time.Minute, in_mem.Hooks{}),
+				rates:   newFreshRates(map[string]decimal.Decimal{"USD": decimal.NewFromInt(1), "EUR": decimal.NewFromInt(2)}),
+			}
+			recorder := httptest.NewRecorder()
+
+			newWalletRouter(a, "a").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/exchange", strings.NewReader(`{"quote_id": "`+quoteId+`"}`)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if got := storage.wallets["a"]["EUR"]; !got.Equal(decimal.RequireFromString(tt.wantEUR)) {
+				t.Errorf("EUR balance = %v, want %v", got, tt.wantEUR)
+			}
+
+			if tt.wantCode != "" {
+				var got ErrResponseJSON
+				if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Code != tt.wantCode {
+					t.Errorf("code = %s, want %s", got.Code, tt.wantCode)
+				}
+				return
+			}
+
+			var got ExchangeResponseJSON
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.ExchangeAmount.Equal(quote.ToAmount) || !got.NewBalance["USD"].Equal(decimal.NewFromInt(90)) {
+				t.Errorf("response = %+v", got)
+			}
+			if storage.quotes[quoteId].UsedAt.IsZero() {
+				t.Errorf("quote was not marked used")
+			}
+		})
+	}
+}
+
+func TestApp_Exchange_fee(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		spread      string
+		wantEUR     string
+		wantHouse   string
+		wantFeeRows int
+	}{
+		{name: "комиссия зачисляется на кошелек сервиса", spread: "1", wantEUR: "14.85", wantHouse: "0.15", wantFeeRows: 1},
+		{name: "без комиссии", spread: "0", wantEUR: "15", wantHouse: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newFees(config.ExchangeConfig{Spread: tt.spread})
+			if err != nil {
+				t.Fatalf("newFees() error = %v", err)
+			}
+			storage := newWalletStorage(map[string]storages.Balance{
+				"a":     {"USD": decimal.NewFromInt(100)},
+				"house": {},
+			}, nil)
+			a := &App{
+				storage: storage,
+				cache:   in_mem.New(time.Minute, in_mem.Hooks{}),
+				rates:   newFreshRates(map[string]decimal.Decimal{"USD": decimal.NewFromInt(1), "EUR": decimal.NewFromInt(2)}),
+				fees:    f,
+			}
+			a.cfg.Exchange.HouseWallet = "house"
+			recorder := httptest.NewRecorder()
+
+			newWalletRouter(a, "a").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/exchange", strings.NewReader(`{"from_currency": "USD", "to_currency": "EUR", "amount": "30"}`)))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body.String())
+			}
+			if got := storage.wallets["a"]["EUR"]; !got.Equal(decimal.RequireFromString(tt.wantEUR)) {
+				t.Errorf("EUR balance of a = %v, want %v", got, tt.wantEUR)
+			}
+			if got := storage.wallets["house"]["EUR"]; !got.Equal(decimal.RequireFromString(tt.wantHouse)) {
+				t.Errorf("EUR balance of house = %v, want %v", got, tt.wantHouse)
+			}
+
+			var feeRows []storages.Transaction
+			for _, transaction := range storage.transactions {
+				if transaction.Kind == storages.KindFee {
+					feeRows = append(feeRows, transaction)
+				}
+			}
+			if len(feeRows) != tt.wantFeeRows {
+				t.Fatalf("fee rows = %+v, want %d", feeRows, tt.wantFeeRows)
+			}
+			for _, row := range feeRows {
+				if row.UserId != "house" || row.CounterpartyId != "a" || row.Currency != "EUR" || !row.Amount.Equal(decimal.RequireFromString(tt.wantHouse)) {
+					t.Errorf("fee row = %+v", row)
+				}
+			}
+			if locked := slices.Contains(storage.locks, "house"); locked != (tt.wantFeeRows > 0) {
+				t.Errorf("house wallet locked = %v, locks = %v", locked, storage.locks)
+			}
+		})
+	}
+}
+
+func TestApp_priceExchange_sameCurrency(t *testing.T) {
+	a := &App{}
+
+	_, _, _, err := a.priceExchange("USD", decimal.NewFromInt(10), storages.Currency{Code: "USD", MinorUnits: 2, Enabled: true})
+	if !errors.Is(err, sameCurrencyErr) {
+		t.Errorf("priceExchange() error = %v, want %v", err, sameCurrencyErr)
+	}
+}
+
+func newAdminRouter(a *App) *gin.Engine {
+	router := gin.New()
+	admin := router.Group("/wallets/:user_id", func(c *gin.Context) {
+		c.Set(principalKey, Principal{UserId: "admin", Roles: []string{RoleAdmin}})
+	})
+	admin.POST("/freeze", a.FreezeWallet)
+	admin.POST("/unfreeze", a.UnfreezeWallet)
+	admin.POST("/close", a.CloseWallet)
+	admin.POST("/adjustments", a.AdjustWallet)
+	admin.GET("/transactions", a.AdminTransactions)
+	return router
+}
+
+func TestApp_setWalletStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		status     string
+		path       string
+		wantStatus int
+		wantCode   ErrorCode
+		wantAudit  int
+	}{
+		{name: "заморозка активного", status: storages.WalletActive, path: "/freeze", wantStatus: http.StatusOK, wantAudit: 1},
+		{name: "заморозка замороженного", status: storages.WalletFrozen, path: "/freeze", wantStatus: http.StatusConflict, wantCode: CodeWalletStatusUnchanged},
+		{name: "разморозка активного", status: storages.WalletActive, path: "/unfreeze", wantStatus: http.StatusConflict, wantCode: CodeWalletStatusUnchanged},
+		{name: "разморозка закрытого", status: storages.WalletClosed, path: "/unfreeze", wantStatus: http.StatusConflict, wantCode: CodeWalletClosed},
+		{name: "закрытие закрытого", status: storages.WalletClosed, path: "/close", wantStatus: http.StatusConflict, wantCode: CodeWalletClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{"a": {"USD": decimal.NewFromInt(100)}}, nil)
+			storage.statuses = map[string]string{"a": tt.status}
+			a := &App{storage: storage, cache: in_mem.New(time.Minute, in_mem.Hooks{})}
+			recorder := httptest.NewRecorder()
+
+			newAdminRouter(a).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/wallets/a"+tt.path, strings.NewReader(`{"reason": "test"}`)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if len(storage.audit) != tt.wantAudit {
+				t.Errorf("audit records = %d, want %d", len(storage.audit), tt.wantAudit)
+			}
+			if tt.wantCode != "" {
+				var got ErrResponseJSON
+				if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Code != tt.wantCode {
+					t.Errorf("code = %s, want %s", got.Code, tt.wantCode)
+				}
+				return
+			}
+
+			var got WalletResponseJSON
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != storages.WalletFrozen || got.Tier != storages.DefaultTier || !got.Balance["USD"].Equal(decimal.NewFromInt(100)) {
+				t.Errorf("response = %+v", got)
+			}
+		})
+	}
+}
+
+func TestApp_AdjustWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		status      string
+		wantStatus  int
+		wantBalance string
+		wantAudit   int
+	}{
+		{name: "активный кошелек", status: storages.WalletActive, wantStatus: http.StatusOK, wantBalance: "90", wantAudit: 1},
+		{name: "замороженный кошелек", status: storages.WalletFrozen, wantStatus: http.StatusOK, wantBalance: "90", wantAudit: 1},
+		{name: "закрытый кошелек", status: storages.WalletClosed, wantStatus: http.StatusConflict, wantBalance: "100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{"a": {"USD": decimal.NewFromInt(100)}}, nil)
+			storage.statuses = map[string]string{"a": tt.status}
+			a := &App{storage: storage, cache: in_mem.New(time.Minute, in_mem.Hooks{})}
+			recorder := httptest.NewRecorder()
+
+			newAdminRouter(a).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/wallets/a/adjustments", strings.NewReader(`{"currency": "USD", "amount": "-10", "reason": "test"}`)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if got := storage.wallets["a"]["USD"]; !got.Equal(decimal.RequireFromString(tt.wantBalance)) {
+				t.Errorf("balance = %v, want %v", got, tt.wantBalance)
+			}
+			if len(storage.audit) != tt.wantAudit {
+				t.Errorf("audit records = %d, want %d", len(storage.audit), tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestApp_AdminTransactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantAudit  int
+	}{
+		{name: "история кошелька", path: "/wallets/a/transactions?kind=deposit", wantStatus: http.StatusOK, wantAudit: 1},
+		{name: "неверный фильтр", path: "/wallets/a/transactions?kind=gift", wantStatus: http.StatusBadRequest},
+		{name: "неизвестный кошелек", path: "/wallets/b/transactions", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newWalletStorage(map[string]storages.Balance{"a": {"USD": decimal.NewFromInt(100)}}, nil)
+			a := &App{storage: storage}
+			recorder := httptest.NewRecorder()
+
+			newAdminRouter(a).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if len(storage.audit) != tt.wantAudit {
+				t.Errorf("audit records = %d, want %d", len(storage.audit), tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestApp_RateLimitIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verifier, err := tokens.New(config.JWTConfig{SharedKey: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("tokens.New() error = %v", err)
+	}
+	a := &App{
+		tokens:  verifier,
+		cache:   in_mem.New(time.Minute, in_mem.Hooks{}),
+		limiter: ratelimit_in_mem.New(),
+		ipRate:  ratelimit.Rate{Limit: 3, Period: time.Minute},
+	}
+
+	router := gin.New()
+	router.GET("/protected", a.RateLimitIP, a.Authenticate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	var got []int
+	for i := 0; i < 5; i++ {
+		request := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		request.Header.Set("Authorization", "Bearer a.b.c")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		got = append(got, recorder.Code)
+	}
+
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/grpcClient/auth"
+	"gw-currency-wallet/internal/tokens"
+	"gw-currency-wallet/pkg/logs"
+	"time"
+)
+
+const (
+	principalKey        = "principal"
+	tokenCacheKeyPrefix = "token:"
+)
+
+var (
+	unauthenticatedErr = fmt.Errorf("Access deny")
+	tokenRevokedErr    = fmt.Errorf("token revoked")
+)
+
+// Authenticate is the middleware of the protected routes. It checks the token
+// once per request and stores the Principal in the gin context.
+func (a *App) Authenticate(c *gin.Context) {
+	const op = "App Authenticate"
+
+	principal, err := a.authenticate(c)
+	if err != nil {
+		a.sendError(c, op, err)
+		c.Abort()
+		return
+	}
+
+	c.Set(principalKey, principal)
+	c.Request = c.Request.WithContext(logs.ContextWithUserId(c.Request.Context(), principal.UserId))
+	c.Next()
+}
+
+// PrincipalFromContext returns the principal stored by Authenticate.
+func PrincipalFromContext(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+
+	principal, ok := value.(Principal)
+
+	return principal, ok
+}
+
+// currentUser returns the authenticated user id. A handler reached without
+// Authenticate answers 401 rather than running unauthenticated.
+func currentUser(c *gin.Context) (string, error) {
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		writeError(c, unauthenticatedErr)
+		return "", unauthenticatedErr
+	}
+
+	return principal.UserId, nil
+}
+
+func (a *App) authenticate(c *gin.Context) (Principal, error) {
+	const op = "App authenticate"
+
+	ctx := c.Request.Context()
+
+	authStr := c.GetHeader("Authorization")
+
+	if authStr == "" {
+		return Principal{}, fmt.Errorf("%s: %w", op, unauthenticatedErr)
+	}
+
+	token, err := getTokenFromString(authStr)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %w: %w", op, tokens.InvalidTokenErr, err)
+	}
+
+	if value, ok := a.cache.Get(tokenCacheKey(token)); ok {
+		principal := value.(Principal)
+		if err = a.checkRevoked(ctx, principal.TokenId); err != nil {
+			return Principal{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return principal, nil
+	}
+
+	var claims tokens.Claims
+
+	if a.tokens != nil {
+		claims, err = a.tokens.Verify(token)
+	} else {
+		claims, err = tokens.ParseUnverified(token)
+	}
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	principal := Principal{UserId: claims.UserId, Roles: claims.Roles, TokenId: claims.TokenId, ExpiresAt: claims.ExpiresAt}
+	if principal.TokenId == "" {
+		principal.TokenId = tokens.Hash(token)
+	}
+	if len(principal.Roles) == 0 {
+		principal.Roles = []string{RoleUser}
+	}
+
+	if err = a.checkRevoked(ctx, principal.TokenId); err != nil {
+		return Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.tokens != nil && !a.cfg.JWT.CheckRevocation {
+		a.rememberToken(token, principal, claims.ExpiresAt)
+		return principal, nil
+	}
+
+	ok, err := a.verifyToken(ctx, claims.UserId, token)
+
+	switch {
+	case errors.Is(err, auth.InvalidCredentialsErr):
+		return Principal{}, fmt.Errorf("%s: %w", op, tokens.InvalidTokenErr)
+	case err != nil:
+		return Principal{}, fmt.Errorf("%s: %w", op, err)
+	case !ok:
+		return Principal{}, fmt.Errorf("%s: %w", op, unauthenticatedErr)
+	}
+
+	a.rememberToken(token, principal, claims.ExpiresAt)
+
+	return principal, nil
+}
+
+// checkRevoked looks the token up in the revocation list. Cached tokens are
+// checked too: Logout on another instance clears only the cache of that one.
+func (a *App) checkRevoked(ctx context.Context, tokenId string) error {
+	revoked, err := a.storage.IsTokenRevoked(ctx, tokenId)
+	switch {
+	case err != nil:
+		return err
+	case revoked:
+		return tokenRevokedErr
+	}
+
+	return nil
+}
+
+// rememberToken caches a successful verification until the token expires, but
+// no longer than JWT_CACHE_TTL. The cache is keyed by the token hash, so the
+// token itself is not kept in memory.
+func (a *App) rememberToken(token string, principal Principal, expiresAt time.Time) {
+	ttl := time.Duration(a.cfg.JWT.CacheTTL) * time.Second
+	if !expiresAt.IsZero() {
+		ttl = min(ttl, time.Until(expiresAt))
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	a.cache.SetWithTTL(tokenCacheKey(token), principal, ttl)
+}
+
+// forgetToken drops a cached verification, so a logged out token is checked again.
+func (a *App) forgetToken(token string) {
+	a.cache.Delete(tokenCacheKey(token))
+}
+
+func tokenCacheKey(token string) string {
+	return tokenCacheKeyPrefix + tokens.Hash(token)
+}
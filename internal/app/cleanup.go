@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// RunCleanup periodically removes expired records until ctx is done.
+func (a *App) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.cleanup(ctx)
+		}
+	}
+}
+
+func (a *App) cleanup(ctx context.Context) {
+	const op = "App cleanup"
+
+	if _, err := a.storage.DeleteIdempotencyRecords(ctx, time.Now().Add(-idempotencyKeyLifetime)); err != nil {
+		a.logger.Err(ctx, op, err)
+	}
+
+	if _, err := a.storage.DeleteQuotes(ctx, time.Now().Add(-time.Hour)); err != nil {
+		a.logger.Err(ctx, op, err)
+	}
+
+	if _, err := a.storage.DeleteExpiredTokens(ctx, time.Now()); err != nil {
+		a.logger.Err(ctx, op, err)
+	}
+}
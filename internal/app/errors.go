@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/grpcClient"
+	"gw-currency-wallet/internal/grpcClient/auth"
+	"gw-currency-wallet/internal/rates"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/tokens"
+	"gw-currency-wallet/pkg/logs"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode tells the client why a request failed. Codes are stable, unlike
+// the messages, so clients branch on them.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	CodeInvalidIdempotencyKey ErrorCode = "INVALID_IDEMPOTENCY_KEY"
+	CodeIdempotencyKeyReused  ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeUnknownCurrency       ErrorCode = "UNKNOWN_CURRENCY"
+	CodeSameCurrency          ErrorCode = "SAME_CURRENCY"
+	CodeInvalidAmount         ErrorCode = "INVALID_AMOUNT"
+	CodeInsufficientFunds     ErrorCode = "INSUFFICIENT_FUNDS"
+	CodeSelfTransfer          ErrorCode = "SELF_TRANSFER"
+	CodeLimitExceeded         ErrorCode = "LIMIT_EXCEEDED"
+	CodeUnauthenticated       ErrorCode = "UNAUTHENTICATED"
+	CodeInvalidCredentials    ErrorCode = "INVALID_CREDENTIALS"
+	CodeTokenInvalid          ErrorCode = "TOKEN_INVALID"
+	CodeTokenExpired          ErrorCode = "TOKEN_EXPIRED"
+	CodeTokenRevoked          ErrorCode = "TOKEN_REVOKED"
+	CodeRefreshTokenInvalid   ErrorCode = "REFRESH_TOKEN_INVALID"
+	CodeTokenIssuingDisabled  ErrorCode = "TOKEN_ISSUING_DISABLED"
+	CodeForbidden             ErrorCode = "FORBIDDEN"
+	CodeUserExists            ErrorCode = "USER_EXISTS"
+	CodeWalletNotFound        ErrorCode = "WALLET_NOT_FOUND"
+	CodeRecipientNotFound     ErrorCode = "RECIPIENT_NOT_FOUND"
+	CodeWalletFrozen          ErrorCode = "WALLET_FROZEN"
+	CodeWalletClosed          ErrorCode = "WALLET_CLOSED"
+	CodeWalletStatusUnchanged ErrorCode = "WALLET_STATUS_UNCHANGED"
+	CodeRecipientClosed       ErrorCode = "RECIPIENT_CLOSED"
+	CodeReasonRequired        ErrorCode = "REASON_REQUIRED"
+	CodeUnknownTier           ErrorCode = "UNKNOWN_TIER"
+	CodeInvalidQuote          ErrorCode = "INVALID_QUOTE"
+	CodeQuoteNotFound         ErrorCode = "QUOTE_NOT_FOUND"
+	CodeQuoteUsed             ErrorCode = "QUOTE_USED"
+	CodeQuoteExpired          ErrorCode = "QUOTE_EXPIRED"
+	CodeRatesUnavailable      ErrorCode = "RATES_UNAVAILABLE"
+	CodeRateLimited           ErrorCode = "RATE_LIMITED"
+	CodeUpstreamUnavailable   ErrorCode = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamTimeout       ErrorCode = "UPSTREAM_TIMEOUT"
+	CodeInternal              ErrorCode = "INTERNAL"
+)
+
+const problemContentType = "application/problem+json"
+
+var invalidRequestErr = fmt.Errorf("invalid request")
+
+// apiError is an answer with its own status and code, for the failures that
+// have no sentinel in the catalog.
+type apiError struct {
+	status  int
+	code    ErrorCode
+	message string
+	details any
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// badRequest answers 400 INVALID_REQUEST with the message of err.
+func badRequest(err error) error {
+	return &apiError{status: http.StatusBadRequest, code: CodeInvalidRequest, message: err.Error()}
+}
+
+// catalog maps the domain errors to the answers; the first match wins. The
+// message is the text of the error unless it is set.
+var catalog = []struct {
+	err     error
+	status  int
+	code    ErrorCode
+	message string
+}{
+	{err: invalidRequestErr, status: http.StatusBadRequest, code: CodeInvalidRequest},
+	{err: recipientRequiredErr, status: http.StatusBadRequest, code: CodeInvalidRequest},
+	{err: recipientAmbiguousErr, status: http.StatusBadRequest, code: CodeInvalidRequest},
+	{err: invalidIdempotencyKeyErr, status: http.StatusBadRequest, code: CodeInvalidIdempotencyKey},
+	{err: idempotencyMismatchErr, status: http.StatusUnprocessableEntity, code: CodeIdempotencyKeyReused},
+	{err: unknownCurrencyErr, status: http.StatusBadRequest, code: CodeUnknownCurrency},
+	{err: sameCurrencyErr, status: http.StatusBadRequest, code: CodeSameCurrency},
+	{err: invalidAmountErr, status: http.StatusBadRequest, code: CodeInvalidAmount},
+	{err: amountPrecisionErr, status: http.StatusBadRequest, code: CodeInvalidAmount},
+	{err: amountTooSmallErr, status: http.StatusBadRequest, code: CodeInvalidAmount},
+	{err: amountTooLargeErr, status: http.StatusBadRequest, code: CodeInvalidAmount},
+	{err: insufficientFundsErr, status: http.StatusBadRequest, code: CodeInsufficientFunds},
+	{err: selfTransferErr, status: http.StatusBadRequest, code: CodeSelfTransfer},
+	{err: unauthenticatedErr, status: http.StatusUnauthorized, code: CodeUnauthenticated},
+	{err: auth.InvalidCredentialsErr, status: http.StatusUnauthorized, code: CodeInvalidCredentials, message: "invalid username or password"},
+	{err: tokens.ExpiredTokenErr, status: http.StatusUnauthorized, code: CodeTokenExpired},
+	{err: tokens.InvalidTokenErr, status: http.StatusUnauthorized, code: CodeTokenInvalid},
+	{err: tokenRevokedErr, status: http.StatusUnauthorized, code: CodeTokenRevoked},
+	{err: storages.RefreshTokenNotFoundErr, status: http.StatusUnauthorized, code: CodeRefreshTokenInvalid, message: "invalid refresh token"},
+	{err: tokens.CannotIssueErr, status: http.StatusNotImplemented, code: CodeTokenIssuingDisabled},
+	{err: forbiddenErr, status: http.StatusForbidden, code: CodeForbidden},
+	{err: auth.UserAlreadyExistsErr, status: http.StatusBadRequest, code: CodeUserExists, message: "username or email already exists"},
+	{err: recipientNotFoundErr, status: http.StatusNotFound, code: CodeRecipientNotFound},
+	{err: storages.WalletNotFoundErr, status: http.StatusNotFound, code: CodeWalletNotFound},
+	{err: walletFrozenErr, status: http.StatusLocked, code: CodeWalletFrozen},
+	{err: walletClosedErr, status: http.StatusLocked, code: CodeWalletClosed},
+	{err: walletAlreadyClosedErr, status: http.StatusConflict, code: CodeWalletClosed},
+	{err: walletStatusUnchangedErr, status: http.StatusConflict, code: CodeWalletStatusUnchanged},
+	{err: recipientClosedErr, status: http.StatusLocked, code: CodeRecipientClosed},
+	{err: reasonRequiredErr, status: http.StatusBadRequest, code: CodeReasonRequired},
+	{err: unknownTierErr, status: http.StatusBadRequest, code: CodeUnknownTier},
+	{err: invalidQuoteErr, status: http.StatusBadRequest, code: CodeInvalidQuote},
+	{err: storages.QuoteNotFoundErr, status: http.StatusNotFound, code: CodeQuoteNotFound},
+	{err: quoteUsedErr, status: http.StatusConflict, code: CodeQuoteUsed},
+	{err: quoteExpiredErr, status: http.StatusGone, code: CodeQuoteExpired},
+	{err: rates.StaleRatesErr, status: http.StatusServiceUnavailable, code: CodeRatesUnavailable, message: "exchange rates are unavailable"},
+	{err: ratesUnavailableErr, status: http.StatusServiceUnavailable, code: CodeRatesUnavailable},
+	{err: invalidRateErr, status: http.StatusServiceUnavailable, code: CodeRatesUnavailable},
+	{err: tooManyRequestsErr, status: http.StatusTooManyRequests, code: CodeRateLimited},
+	{err: context.DeadlineExceeded, status: http.StatusGatewayTimeout, code: CodeUpstreamTimeout, message: "upstream timeout"},
+	{err: grpcClient.UnavailableErr, status: http.StatusServiceUnavailable, code: CodeUpstreamUnavailable, message: "upstream unavailable"},
+}
+
+// lookupError finds the answer to err. An unknown error is an internal one
+// and its text is not shown to the client.
+func lookupError(err error) *apiError {
+	var result *apiError
+	if errors.As(err, &result) {
+		return result
+	}
+
+	var limitErr *limitError
+	if errors.As(err, &limitErr) {
+		return limitErr.apiError()
+	}
+
+	for _, entry := range catalog {
+		if !errors.Is(err, entry.err) {
+			continue
+		}
+
+		message := entry.message
+		if message == "" {
+			message = entry.err.Error()
+		}
+
+		return &apiError{status: entry.status, code: entry.code, message: message}
+	}
+
+	return &apiError{status: http.StatusInternalServerError, code: CodeInternal, message: "internal error"}
+}
+
+// sendError answers with the catalog entry of err. Server-side failures are
+// logged with the args; a request the client abandoned is answered 499
+// without a body, since nobody reads it.
+func (a *App) sendError(c *gin.Context, op string, err error, args ...any) {
+	if errors.Is(err, context.Canceled) {
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+
+	if lookupError(err).status >= http.StatusInternalServerError {
+		a.logger.Err(c.Request.Context(), op, err, args...)
+	}
+
+	writeError(c, err)
+}
+
+// writeError answers with the catalog entry of err as ErrResponseJSON, or as
+// ProblemJSON (RFC 7807) when the client accepts application/problem+json.
+func writeError(c *gin.Context, err error) {
+	e := lookupError(err)
+	requestId := logs.RequestId(c.Request.Context())
+
+	if !strings.Contains(c.GetHeader("Accept"), problemContentType) {
+		c.JSON(e.status, ErrResponseJSON{Code: e.code, Message: e.message, Details: e.details, RequestId: requestId})
+		return
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.JSON(e.status, ProblemJSON{
+		Type:      "urn:gw-currency-wallet:error:" + string(e.code),
+		Title:     http.StatusText(e.status),
+		Status:    e.status,
+		Detail:    e.message,
+		Instance:  c.Request.URL.Path,
+		Code:      e.code,
+		Details:   e.details,
+		RequestId: requestId,
+	})
+}
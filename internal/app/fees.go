@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/storages"
+	"strings"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// fees is the exchange fee model. The fee is taken in the target currency: a
+// percentage spread of the converted amount, but not less than the minimum fee
+// of that currency. Exchanges up to the fee-free amount of the source currency
+// are not charged.
+type fees struct {
+	spread      decimal.Decimal
+	pairSpreads map[string]decimal.Decimal
+	minFee      map[string]decimal.Decimal
+	feeFree     map[string]decimal.Decimal
+}
+
+func newFees(cfg config.ExchangeConfig) (fees, error) {
+	const op = "App newFees"
+
+	var (
+		result fees
+		err    error
+	)
+
+	if result.spread, err = parseRate(cfg.Spread); err != nil {
+		return fees{}, fmt.Errorf("%s: EXCHANGE_SPREAD: %w", op, err)
+	}
+
+	if result.pairSpreads, err = parseDecimalMap(cfg.PairSpreads); err != nil {
+		return fees{}, fmt.Errorf("%s: EXCHANGE_PAIR_SPREADS: %w", op, err)
+	}
+
+	if result.minFee, err = parseDecimalMap(cfg.MinFee); err != nil {
+		return fees{}, fmt.Errorf("%s: EXCHANGE_MIN_FEE: %w", op, err)
+	}
+
+	if result.feeFree, err = parseDecimalMap(cfg.FeeFree); err != nil {
+		return fees{}, fmt.Errorf("%s: EXCHANGE_FEE_FREE: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// apply splits the converted amount into the part credited to the user and the fee.
+func (f fees) apply(from string, amount decimal.Decimal, to storages.Currency, converted decimal.Decimal) (net, fee decimal.Decimal, err error) {
+	if limit, ok := f.feeFree[from]; ok && amount.LessThanOrEqual(limit) {
+		return converted, decimal.Zero, nil
+	}
+
+	spread := f.spread
+	if pairSpread, ok := f.pairSpreads[from+"/"+to.Code]; ok {
+		spread = pairSpread
+	}
+
+	fee = converted.Mul(spread).Div(hundred).RoundCeil(to.MinorUnits)
+	if minFee, ok := f.minFee[to.Code]; ok && fee.LessThan(minFee) {
+		fee = minFee
+	}
+
+	net = converted.Sub(fee)
+	if !net.IsPositive() {
+		return decimal.Zero, decimal.Zero, amountTooSmallErr
+	}
+
+	return net, fee, nil
+}
+
+func parseRate(value string) (decimal.Decimal, error) {
+	rate, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if rate.IsNegative() {
+		return decimal.Zero, fmt.Errorf("negative value %s", value)
+	}
+
+	return rate, nil
+}
+
+// parseDecimalMap parses "KEY=value,KEY=value". Keys are upper-cased.
+func parseDecimalMap(value string) (map[string]decimal.Decimal, error) {
+	result := make(map[string]decimal.Decimal)
+
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		key, rawValue, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid item %q", item)
+		}
+
+		parsed, err := parseRate(rawValue)
+		if err != nil {
+			return nil, err
+		}
+
+		result[strings.ToUpper(strings.TrimSpace(key))] = parsed
+	}
+
+	return result, nil
+}
+
+// collectFee credits the exchange fee to the house wallet. The house wallet is
+// always locked after the user's one, so concurrent exchanges cannot deadlock.
+func (a *App) collectFee(ctx context.Context, tx storages.Tx, user, currency string, fee decimal.Decimal) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	house := a.cfg.Exchange.HouseWallet
+
+	balance, err := tx.GetBalanceForUpdate(ctx, house)
+	if err != nil {
+		return err
+	}
+
+	credit, err := changeBalance(currency, balance, fee, decimal.NewFromInt(1))
+	if err != nil {
+		return err
+	}
+
+	if err = tx.UpdateWallet(ctx, house, storages.Balance{currency: balance[currency]}); err != nil {
+		return err
+	}
+
+	return tx.AddTransactions(ctx, storages.Transaction{
+		UserId:         house,
+		Kind:           storages.KindFee,
+		Currency:       currency,
+		Amount:         credit,
+		Balance:        balance[currency],
+		CounterpartyId: user,
+	})
+}
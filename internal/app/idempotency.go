@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyKeyLifetime  = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+var (
+	invalidIdempotencyKeyErr = fmt.Errorf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)
+	idempotencyMismatchErr   = fmt.Errorf("Idempotency-Key was already used with a different request")
+	// idempotencyReplayErr aborts the wallet transaction when the request has already been processed.
+	idempotencyReplayErr = fmt.Errorf("request already processed")
+)
+
+// idempotency tracks the Idempotency-Key of a money-moving request.
+// A nil *idempotency means the client did not send the header.
+type idempotency struct {
+	user   string
+	key    string
+	hash   string
+	replay storages.IdempotencyRecord
+}
+
+// newIdempotency reads the Idempotency-Key header. The request hash covers the
+// method, the route and the raw body, so the same key sent to another endpoint
+// or with another body does not match.
+func newIdempotency(c *gin.Context, user string) (*idempotency, error) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, invalidIdempotencyKeyErr
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	hash.Write(requestBody(c))
+
+	return &idempotency{
+		user: user,
+		key:  key,
+		hash: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// check looks the key up. It must run after the wallet row is locked, so that
+// concurrent retries with the same key are processed one after another.
+func (i *idempotency) check(ctx context.Context, tx storages.Tx) error {
+	if i == nil {
+		return nil
+	}
+
+	record, found, err := tx.GetIdempotencyRecord(ctx, i.user, i.key)
+	if err != nil || !found || time.Since(record.CreatedAt) > idempotencyKeyLifetime {
+		return err
+	}
+
+	if record.RequestHash != i.hash {
+		return idempotencyMismatchErr
+	}
+
+	i.replay = record
+
+	return idempotencyReplayErr
+}
+
+// save stores the response in the same transaction as the wallet change.
+func (i *idempotency) save(ctx context.Context, tx storages.Tx, statusCode int, response any) error {
+	if i == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return tx.SaveIdempotencyRecord(ctx, storages.IdempotencyRecord{
+		UserId:      i.user,
+		Key:         i.key,
+		RequestHash: i.hash,
+		StatusCode:  statusCode,
+		Response:    body,
+	})
+}
+
+// writeReplay sends the stored response of the first request.
+func (i *idempotency) writeReplay(c *gin.Context) {
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(i.replay.StatusCode, binding.MIMEJSON+"; charset=utf-8", i.replay.Response)
+}
+
+// bindJSON decodes the request body and keeps its raw bytes for the idempotency hash.
+func bindJSON(c *gin.Context, obj any) error {
+	return c.ShouldBindBodyWith(obj, binding.JSON)
+}
+
+func requestBody(c *gin.Context) []byte {
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		if raw, ok := body.([]byte); ok {
+			return raw
+		}
+	}
+
+	return nil
+}
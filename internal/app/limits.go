@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	limitWithdrawSingle  = "withdraw_single"
+	limitWithdrawDaily   = "withdraw_daily"
+	limitExchangeMonthly = "exchange_monthly"
+)
+
+// outgoingKinds are counted against the daily withdrawal limit: a transfer to
+// another wallet takes money out of the wallet just like a withdrawal.
+var outgoingKinds = []string{storages.KindWithdraw, storages.KindTransferOut}
+
+// limitError is returned when an operation would exceed a limit. Remaining is
+// what is still allowed until ResetsAt; a single-operation limit never resets.
+type limitError struct {
+	Limit     string
+	Currency  string
+	Max       decimal.Decimal
+	Remaining decimal.Decimal
+	ResetsAt  time.Time
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded", e.Limit)
+}
+
+// limits maps a wallet tier to the limits per currency.
+type limits struct {
+	withdrawSingle  map[string]map[string]decimal.Decimal
+	withdrawDaily   map[string]map[string]decimal.Decimal
+	exchangeMonthly map[string]map[string]decimal.Decimal
+}
+
+func newLimits(cfg config.LimitsConfig) (limits, error) {
+	const op = "App newLimits"
+
+	var (
+		result limits
+		err    error
+	)
+
+	if result.withdrawSingle, err = parseTierMap(cfg.WithdrawSingle); err != nil {
+		return limits{}, fmt.Errorf("%s: LIMITS_WITHDRAW_SINGLE: %w", op, err)
+	}
+
+	if result.withdrawDaily, err = parseTierMap(cfg.WithdrawDaily); err != nil {
+		return limits{}, fmt.Errorf("%s: LIMITS_WITHDRAW_DAILY: %w", op, err)
+	}
+
+	if result.exchangeMonthly, err = parseTierMap(cfg.ExchangeMonthly); err != nil {
+		return limits{}, fmt.Errorf("%s: LIMITS_EXCHANGE_MONTHLY: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// knows reports whether the tier is the default one or has any limit configured.
+func (l limits) knows(tier string) bool {
+	if tier == storages.DefaultTier {
+		return true
+	}
+
+	for _, byTier := range []map[string]map[string]decimal.Decimal{l.withdrawSingle, l.withdrawDaily, l.exchangeMonthly} {
+		if _, ok := byTier[tier]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTierMap parses "tier:KEY=value,KEY=value;tier:KEY=value".
+func parseTierMap(value string) (map[string]map[string]decimal.Decimal, error) {
+	result := make(map[string]map[string]decimal.Decimal)
+
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		tier, rawValue, ok := strings.Cut(item, ":")
+		tier = strings.TrimSpace(tier)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid item %q", item)
+		}
+
+		parsed, err := parseDecimalMap(rawValue)
+		if err != nil {
+			return nil, err
+		}
+
+		result[tier] = parsed
+	}
+
+	return result, nil
+}
+
+// checkWithdrawal enforces the single and the daily withdrawal limits of the
+// wallet tier. The wallet must be locked, so that concurrent debits are counted.
+func (a *App) checkWithdrawal(ctx context.Context, tx storages.Tx, user, currency string, amount decimal.Decimal, now time.Time) error {
+	if len(a.limits.withdrawSingle) == 0 && len(a.limits.withdrawDaily) == 0 {
+		return nil
+	}
+
+	tier, err := tx.GetWalletTier(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if maxAmount, ok := a.limits.withdrawSingle[tier][currency]; ok && amount.GreaterThan(maxAmount) {
+		return &limitError{Limit: limitWithdrawSingle, Currency: currency, Max: maxAmount, Remaining: maxAmount}
+	}
+
+	maxAmount, ok := a.limits.withdrawDaily[tier][currency]
+	if !ok {
+		return nil
+	}
+
+	since := now.UTC().Truncate(24 * time.Hour)
+
+	return a.checkTotal(ctx, tx, limitWithdrawDaily, user, currency, outgoingKinds, since, since.AddDate(0, 0, 1), maxAmount, amount)
+}
+
+// checkExchange enforces the monthly exchange volume of the source currency.
+func (a *App) checkExchange(ctx context.Context, tx storages.Tx, user, currency string, amount decimal.Decimal, now time.Time) error {
+	if len(a.limits.exchangeMonthly) == 0 {
+		return nil
+	}
+
+	tier, err := tx.GetWalletTier(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	maxAmount, ok := a.limits.exchangeMonthly[tier][currency]
+	if !ok {
+		return nil
+	}
+
+	now = now.UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return a.checkTotal(ctx, tx, limitExchangeMonthly, user, currency, []string{storages.KindExchange}, since, since.AddDate(0, 1, 0), maxAmount, amount)
+}
+
+func (a *App) checkTotal(ctx context.Context, tx storages.Tx, limit, user, currency string, kinds []string, since, resetsAt time.Time, maxAmount, amount decimal.Decimal) error {
+	used, err := tx.GetDebitTotal(ctx, storages.DebitFilter{UserId: user, Currency: currency, Kinds: kinds, Since: since})
+	if err != nil {
+		return err
+	}
+
+	return checkRemaining(limit, currency, maxAmount, used, amount, resetsAt)
+}
+
+func (e *limitError) apiError() *apiError {
+	details := LimitDetailsJSON{
+		Limit:     e.Limit,
+		Currency:  e.Currency,
+		Max:       e.Max,
+		Remaining: e.Remaining,
+	}
+	if !e.ResetsAt.IsZero() {
+		details.ResetsAt = &e.ResetsAt
+	}
+
+	return &apiError{status: http.StatusForbidden, code: CodeLimitExceeded, message: e.Error(), details: details}
+}
+
+func checkRemaining(limit, currency string, maxAmount, used, amount decimal.Decimal, resetsAt time.Time) error {
+	remaining := decimal.Max(maxAmount.Sub(used), decimal.Zero)
+	if amount.LessThanOrEqual(remaining) {
+		return nil
+	}
+
+	return &limitError{Limit: limit, Currency: currency, Max: maxAmount, Remaining: remaining, ResetsAt: resetsAt}
+}
@@ -1,23 +1,42 @@
 package app
 
 import (
-	"context"
+	"github.com/shopspring/decimal"
 	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/config"
 	"gw-currency-wallet/internal/grpcClient/auth"
-	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/internal/ratelimit"
+	"gw-currency-wallet/internal/rates"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/tokens"
 	"gw-currency-wallet/pkg/logs"
+	"time"
 )
 
 type App struct {
-	ctx        context.Context
+	cfg        config.Config
+	fees       fees
+	limits     limits
 	storage    storages.Storage
 	cache      cache.Cache
-	exchanger  exchange.Exchanger
+	rates      *rates.Provider
 	authorizer auth.Authorizer
+	tokens     *tokens.Verifier // nil when tokens are verified by gw-authorizer only
+	limiter    ratelimit.Store
+	rateLimits ratelimit.Limits
+	ipRate     ratelimit.Rate // zero when the client IPs are not limited
 	logger     *logs.Log
 }
 
+// Principal is the authenticated caller of a protected route. TokenId is the
+// "jti" claim or, for tokens without it, the token hash.
+type Principal struct {
+	UserId    string
+	Roles     []string
+	TokenId   string
+	ExpiresAt time.Time
+}
+
 type User struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -30,18 +49,62 @@ type Credentials struct {
 }
 
 type Cash struct {
-	Amount   float32 `json:"amount"`
-	Currency string  `json:"currency"`
+	Amount   decimal.Decimal `json:"amount" swaggertype:"string" example:"10.50"`
+	Currency string          `json:"currency"`
 }
 
+// ExchangeRequest either names the currencies and amount or refers to a quote;
+// with quote_id the other fields are ignored.
 type ExchangeRequest struct {
-	FromCurrency string  `json:"from_currency"`
-	ToCurrency   string  `json:"to_currency"`
-	Amount       float32 `json:"amount"`
+	FromCurrency string          `json:"from_currency"`
+	ToCurrency   string          `json:"to_currency"`
+	Amount       decimal.Decimal `json:"amount" swaggertype:"string" example:"10.50"`
+	QuoteId      string          `json:"quote_id,omitempty"`
+}
+
+type QuoteRequest struct {
+	FromCurrency string          `json:"from_currency"`
+	ToCurrency   string          `json:"to_currency"`
+	Amount       decimal.Decimal `json:"amount" swaggertype:"string" example:"10.50"`
 }
 
+// TransferRequest names the recipient by ToUserId or by ToUsername.
+type TransferRequest struct {
+	ToUserId   string          `json:"to_user_id"`
+	ToUsername string          `json:"to_username"`
+	Currency   string          `json:"currency"`
+	Amount     decimal.Decimal `json:"amount" swaggertype:"string" example:"10.50"`
+}
+
+// ErrResponseJSON is the answer to a failed request. Details depend on the code,
+// see LimitDetailsJSON.
 type ErrResponseJSON struct {
-	Error string `json:"error"`
+	Code      ErrorCode `json:"code" example:"INSUFFICIENT_FUNDS"`
+	Message   string    `json:"message" example:"insufficient funds or invalid amount"`
+	Details   any       `json:"details,omitempty" swaggertype:"object"`
+	RequestId string    `json:"request_id,omitempty" example:"3f2b8c1e9a4d4e0f8b6a7c5d2e1f0a9b"`
+}
+
+// ProblemJSON is ErrResponseJSON as an RFC 7807 problem.
+type ProblemJSON struct {
+	Type      string    `json:"type" example:"urn:gw-currency-wallet:error:INSUFFICIENT_FUNDS"`
+	Title     string    `json:"title" example:"Bad Request"`
+	Status    int       `json:"status" example:"400"`
+	Detail    string    `json:"detail" example:"insufficient funds or invalid amount"`
+	Instance  string    `json:"instance" example:"/api/v1/wallet/withdraw"`
+	Code      ErrorCode `json:"code" example:"INSUFFICIENT_FUNDS"`
+	Details   any       `json:"details,omitempty" swaggertype:"object"`
+	RequestId string    `json:"request_id,omitempty"`
+}
+
+// LimitDetailsJSON are the details of LIMIT_EXCEEDED. ResetsAt is absent for
+// the single-operation limit.
+type LimitDetailsJSON struct {
+	Limit     string          `json:"limit" example:"withdraw_daily"`
+	Currency  string          `json:"currency" example:"USD"`
+	Max       decimal.Decimal `json:"max" swaggertype:"string" example:"1000"`
+	Remaining decimal.Decimal `json:"remaining" swaggertype:"string" example:"250.50"`
+	ResetsAt  *time.Time      `json:"resets_at,omitempty"`
 }
 
 type MessageResponseJSON struct {
@@ -49,16 +112,69 @@ type MessageResponseJSON struct {
 }
 
 type TokenResponseJSON struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type NewBalanceResponseJSON struct {
 	Message    string           `json:"message"`
-	NewBalance storages.Balance `json:"new_balance"`
+	NewBalance storages.Balance `json:"new_balance" swaggertype:"object,string"`
 }
 
 type ExchangeResponseJSON struct {
 	Message        string           `json:"message"`
-	ExchangeAmount float32          `json:"exchange_amount"`
-	NewBalance     storages.Balance `json:"new_balance"`
+	ExchangeAmount decimal.Decimal  `json:"exchange_amount" swaggertype:"string"`
+	Fee            decimal.Decimal  `json:"fee" swaggertype:"string"`
+	NewBalance     storages.Balance `json:"new_balance" swaggertype:"object,string"`
+}
+
+type QuoteResponseJSON struct {
+	QuoteId      string          `json:"quote_id"`
+	FromCurrency string          `json:"from_currency"`
+	ToCurrency   string          `json:"to_currency"`
+	Amount       decimal.Decimal `json:"amount" swaggertype:"string"`
+	Rate         decimal.Decimal `json:"rate" swaggertype:"string"`
+	ToAmount     decimal.Decimal `json:"to_amount" swaggertype:"string"`
+	Fee          decimal.Decimal `json:"fee" swaggertype:"string"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+}
+
+// RatesResponseJSON adds the time the rates were received from gw-exchanger.
+type RatesResponseJSON struct {
+	Rates      map[string]decimal.Decimal `json:"rates" swaggertype:"object,string"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	AgeSeconds int64                      `json:"age_seconds"`
+}
+
+type TransactionsResponseJSON struct {
+	Transactions []storages.Transaction `json:"transactions"`
+	NextCursor   string                 `json:"next_cursor,omitempty"`
+}
+
+// WalletResponseJSON is the wallet as seen by support staff.
+type WalletResponseJSON struct {
+	UserId  string           `json:"user_id"`
+	Status  string           `json:"status" example:"active"`
+	Tier    string           `json:"tier,omitempty" example:"unverified"`
+	Balance storages.Balance `json:"balance" swaggertype:"object,string"`
+}
+
+type AdminActionRequest struct {
+	Reason string `json:"reason" example:"chargeback investigation"`
+}
+
+type TierRequest struct {
+	Tier   string `json:"tier" example:"verified"`
+	Reason string `json:"reason" example:"documents checked"`
+}
+
+// AdjustmentRequest credits a positive amount and debits a negative one.
+type AdjustmentRequest struct {
+	Currency string          `json:"currency" example:"USD"`
+	Amount   decimal.Decimal `json:"amount" swaggertype:"string" example:"-10.50"`
+	Reason   string          `json:"reason" example:"duplicate deposit"`
 }
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/storages"
+)
+
+const currenciesCacheKey = "currencies"
+
+// maxAmountDigits bounds the integer and fractional digits of an amount. Larger
+// exponents are rejected before any arithmetic: rescaling a value such as
+// 1e9999999 takes seconds of CPU.
+const maxAmountDigits = 18
+
+var (
+	invalidAmountErr   = fmt.Errorf("the amount must be greater than 0")
+	amountPrecisionErr = fmt.Errorf("the amount has more decimal places than the currency allows")
+	amountTooSmallErr  = fmt.Errorf("the amount is too small to exchange")
+	amountTooLargeErr  = fmt.Errorf("the amount is too large")
+	invalidRateErr     = fmt.Errorf("invalid exchange rate")
+)
+
+// currencies returns the currency reference table keyed by code. The table is
+// cached, so a currency enabled in the database is picked up within the cache lifetime.
+func (a *App) currencies(ctx context.Context) (map[string]storages.Currency, error) {
+	const op = "App currencies"
+
+	if value, ok := a.cache.Get(currenciesCacheKey); ok {
+		return value.(map[string]storages.Currency), nil
+	}
+
+	list, err := a.storage.GetCurrencies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := make(map[string]storages.Currency, len(list))
+	for _, currency := range list {
+		result[currency.Code] = currency
+	}
+
+	a.cache.Set(currenciesCacheKey, result)
+
+	return result, nil
+}
+
+// lookupCurrency finds an enabled currency by its code.
+func lookupCurrency(currencies map[string]storages.Currency, code string) (storages.Currency, error) {
+	currency, ok := currencies[code]
+	if !ok || !currency.Enabled {
+		return storages.Currency{}, unknownCurrencyErr
+	}
+
+	return currency, nil
+}
+
+// withEnabledCurrencies adds zero amounts for enabled currencies the wallet has never held.
+func withEnabledCurrencies(balance storages.Balance, currencies map[string]storages.Currency) storages.Balance {
+	result := make(storages.Balance, len(currencies))
+	for code, currency := range currencies {
+		if currency.Enabled {
+			result[code] = decimal.Zero
+		}
+	}
+
+	for code, amount := range balance {
+		result[code] = amount
+	}
+
+	return result
+}
+
+// validateAmount accepts only positive amounts of at most maxAmountDigits integer
+// digits that are representable in the currency's minor units.
+func validateAmount(currency storages.Currency, amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return invalidAmountErr
+	}
+
+	if amount.NumDigits()+int(amount.Exponent()) > maxAmountDigits {
+		return amountTooLargeErr
+	}
+
+	if amount.Exponent() < -maxAmountDigits {
+		return amountPrecisionErr
+	}
+
+	if !amount.Equal(amount.Truncate(currency.MinorUnits)) {
+		return amountPrecisionErr
+	}
+
+	return nil
+}
+
+// priceExchange prices an exchange with the rates of the provider, the same
+// for quotes and direct exchanges, so that neither path gets a better rate.
+// The fee is already taken from toAmount.
+func (a *App) priceExchange(from string, amount decimal.Decimal, to storages.Currency) (rate, toAmount, fee decimal.Decimal, err error) {
+	if from == to.Code {
+		return decimal.Zero, decimal.Zero, decimal.Zero, sameCurrencyErr
+	}
+
+	snapshot, err := a.rates.Fresh()
+	if err != nil {
+		return decimal.Zero, decimal.Zero, decimal.Zero, err
+	}
+
+	fromRate, ok := snapshot.Rates.Rates[from]
+	if !ok {
+		return decimal.Zero, decimal.Zero, decimal.Zero, unknownCurrencyErr
+	}
+
+	toRate, ok := snapshot.Rates.Rates[to.Code]
+	if !ok {
+		return decimal.Zero, decimal.Zero, decimal.Zero, unknownCurrencyErr
+	}
+
+	converted, err := convert(amount, fromRate, toRate, to)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, decimal.Zero, err
+	}
+
+	toAmount, fee, err = a.fees.apply(from, amount, to, converted)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, decimal.Zero, err
+	}
+
+	return fromRate.Div(toRate), toAmount, fee, nil
+}
+
+// convert exchanges amount at fromRate/toRate. The result is rounded down to the
+// minor units of the target currency, so the wallet is never credited a fraction
+// of a kopeck or cent the rate did not pay for.
+func convert(amount, fromRate, toRate decimal.Decimal, to storages.Currency) (decimal.Decimal, error) {
+	if !toRate.IsPositive() || !fromRate.IsPositive() {
+		return decimal.Zero, invalidRateErr
+	}
+
+	result := amount.Mul(fromRate).Div(toRate).RoundFloor(to.MinorUnits)
+	if !result.IsPositive() {
+		return decimal.Zero, amountTooSmallErr
+	}
+
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	quoteUsedErr    = fmt.Errorf("quote already used")
+	quoteExpiredErr = fmt.Errorf("quote expired")
+	invalidQuoteErr = fmt.Errorf("invalid quote_id")
+
+	quoteIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// @Summary Quote
+// @Security ApiKeyAuth
+// @Tags Exchange
+// @Descriotion lock an exchange rate for a short time; execute it with quote_id in /api/v1/exchange
+// @ID exchange-quote
+// @Accept json
+// @Produce json
+// @Param input body QuoteRequest true "currencies and amount to exchange"
+// @Success 200 {object} QuoteResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 503 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/exchange/quote [post]
+func (a *App) Quote(c *gin.Context) {
+	const op = "App Quote"
+
+	ctx := c.Request.Context()
+
+	user, err := currentUser(c)
+	if err != nil {
+		return
+	}
+
+	var request QuoteRequest
+
+	if err = c.ShouldBindJSON(&request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	request.FromCurrency, request.ToCurrency = strings.ToUpper(request.FromCurrency), strings.ToUpper(request.ToCurrency)
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	fromCurrency, err := lookupCurrency(currencies, request.FromCurrency)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	toCurrency, err := lookupCurrency(currencies, request.ToCurrency)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if err = validateAmount(fromCurrency, request.Amount); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	rate, toAmount, fee, err := a.priceExchange(request.FromCurrency, request.Amount, toCurrency)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	quote, err := a.storage.CreateQuote(ctx, storages.Quote{
+		UserId:       user,
+		FromCurrency: request.FromCurrency,
+		ToCurrency:   request.ToCurrency,
+		Amount:       request.Amount,
+		Rate:         rate,
+		ToAmount:     toAmount,
+		Fee:          fee,
+		ExpiresAt:    time.Now().Add(time.Duration(a.cfg.Exchange.QuoteTTL) * time.Second),
+	})
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, QuoteResponseJSON{
+		QuoteId:      quote.Id,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Amount:       quote.Amount,
+		Rate:         quote.Rate,
+		ToAmount:     quote.ToAmount,
+		Fee:          quote.Fee,
+		ExpiresAt:    quote.ExpiresAt,
+	})
+}
+
+// useQuote locks the user's quote and marks it used. A quote of another user
+// is reported as not found.
+func (a *App) useQuote(ctx context.Context, tx storages.Tx, user, id string) (storages.Quote, error) {
+	quote, err := tx.GetQuoteForUpdate(ctx, id)
+	if err != nil {
+		return storages.Quote{}, err
+	}
+
+	if err = checkQuote(quote, user, time.Now()); err != nil {
+		return storages.Quote{}, err
+	}
+
+	if err = tx.MarkQuoteUsed(ctx, id); err != nil {
+		return storages.Quote{}, err
+	}
+
+	return quote, nil
+}
+
+func checkQuote(quote storages.Quote, user string, now time.Time) error {
+	switch {
+	case quote.UserId != user:
+		return storages.QuoteNotFoundErr
+	case !quote.UsedAt.IsZero():
+		return quoteUsedErr
+	case !now.Before(quote.ExpiresAt):
+		return quoteExpiredErr
+	}
+
+	return nil
+}
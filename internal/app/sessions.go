@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/tokens"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// privilegedRoles are not carried over by a refresh. gw-authorizer cannot be asked
+// for the current roles of a user, so a revoked support or admin role would
+// otherwise live as long as the refresh token; they come back with the next login.
+var privilegedRoles = []string{RoleSupport, RoleAdmin}
+
+// @Summary Refresh
+// @Tags Auth
+// @Descriotion exchange a refresh token for a new access token and a new refresh token
+// @ID refresh-token
+// @Accept json
+// @Produce json
+// @Param input body RefreshRequest true "refresh token from login or the previous refresh"
+// @Success 200 {object} TokenResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Failure 501 {object} ErrResponseJSON
+// @Router /api/v1/token/refresh [post]
+func (a *App) Refresh(c *gin.Context) {
+	const op = "App Refresh"
+
+	ctx := c.Request.Context()
+
+	if !a.tokens.CanIssue() {
+		a.sendError(c, op, tokens.CannotIssueErr)
+		return
+	}
+
+	var request RefreshRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	refreshToken, err := a.storage.UseRefreshToken(ctx, tokens.Hash(request.RefreshToken))
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	roles := refreshRoles(refreshToken.Roles)
+
+	accessToken, _, err := a.tokens.Issue(refreshToken.UserId, roles, time.Duration(a.cfg.JWT.AccessTTL)*time.Second)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	newRefreshToken, err := a.issueRefreshToken(ctx, refreshToken.UserId, roles)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponseJSON{Token: accessToken, RefreshToken: newRefreshToken})
+}
+
+// @Summary Logout
+// @Security ApiKeyAuth
+// @Tags Auth
+// @Descriotion revoke the access token and, if passed, the refresh token
+// @ID logout
+// @Accept json
+// @Produce json
+// @Param input body RefreshRequest false "refresh token to revoke"
+// @Success 200 {object} MessageResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/logout [post]
+func (a *App) Logout(c *gin.Context) {
+	const op = "App Logout"
+
+	ctx := c.Request.Context()
+
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		a.sendError(c, op, unauthenticatedErr)
+		return
+	}
+
+	var request RefreshRequest
+	_ = c.ShouldBindJSON(&request)
+
+	if request.RefreshToken != "" {
+		if err := a.storage.DeleteRefreshToken(ctx, principal.UserId, tokens.Hash(request.RefreshToken)); err != nil {
+			a.sendError(c, op, err)
+			return
+		}
+	}
+
+	// A token without exp never expires by itself, so its revocation is kept as
+	// long as a session can last.
+	expiresAt := principal.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(time.Duration(a.cfg.JWT.RefreshTTL) * time.Second)
+	}
+
+	if err := a.storage.RevokeToken(ctx, principal.TokenId, expiresAt); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if token, err := getTokenFromString(c.GetHeader("Authorization")); err == nil {
+		a.forgetToken(token)
+	}
+
+	c.JSON(http.StatusOK, MessageResponseJSON{"Logged out"})
+}
+
+// refreshRoles drops the privileged roles captured at login.
+func refreshRoles(roles []string) []string {
+	return slices.DeleteFunc(slices.Clone(roles), func(role string) bool {
+		return slices.Contains(privilegedRoles, role)
+	})
+}
+
+func (a *App) issueRefreshToken(ctx context.Context, user string, roles []string) (string, error) {
+	token, hash, err := tokens.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.storage.SaveRefreshToken(ctx, storages.RefreshToken{
+		Hash:      hash,
+		UserId:    user,
+		Roles:     roles,
+		ExpiresAt: time.Now().Add(time.Duration(a.cfg.JWT.RefreshTTL) * time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
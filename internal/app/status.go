@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+)
+
+var (
+	walletFrozenErr        = fmt.Errorf("wallet is frozen")
+	walletClosedErr        = fmt.Errorf("wallet is closed")
+	recipientClosedErr     = fmt.Errorf("recipient wallet is closed")
+	walletAlreadyClosedErr = fmt.Errorf("wallet is already closed")
+	// walletStatusUnchangedErr refuses to freeze a frozen wallet or unfreeze an active one.
+	walletStatusUnchangedErr = fmt.Errorf("wallet already has this status")
+)
+
+// checkDebit refuses to take money out of a frozen or closed wallet. Like
+// checkCredit, it must run after the wallet is locked, so that a status change
+// cannot slip in before the update.
+func (a *App) checkDebit(ctx context.Context, tx storages.Tx, user string) error {
+	status, err := tx.GetWalletStatus(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return debitAllowed(status)
+}
+
+// checkCredit refuses to put money into a closed wallet. A frozen wallet still
+// accepts deposits and incoming transfers.
+func (a *App) checkCredit(ctx context.Context, tx storages.Tx, user string) error {
+	status, err := tx.GetWalletStatus(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return creditAllowed(status)
+}
+
+func debitAllowed(status string) error {
+	switch status {
+	case storages.WalletFrozen:
+		return walletFrozenErr
+	case storages.WalletClosed:
+		return walletClosedErr
+	}
+
+	return nil
+}
+
+func creditAllowed(status string) error {
+	if status == storages.WalletClosed {
+		return walletClosedErr
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/ratelimit"
+	"math"
+	"strconv"
+)
+
+var tooManyRequestsErr = fmt.Errorf("too many requests")
+
+// RateLimit throttles requests per route: by user id behind Authenticate and
+// by client IP on the public routes. A failing store lets requests through.
+func (a *App) RateLimit(c *gin.Context) {
+	const op = "App RateLimit"
+
+	route := c.Request.Method + " " + c.FullPath()
+
+	rate, ok := a.rateLimits.For(route)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	subject := "ip:" + c.ClientIP()
+	if principal, ok := PrincipalFromContext(c); ok {
+		subject = "user:" + principal.UserId
+	}
+
+	a.throttle(c, op, route+"|"+subject, rate)
+}
+
+// RateLimitIP throttles a client IP over all the routes behind it together. It
+// runs before Authenticate, so that a flood of bad tokens is stopped before it
+// reaches gw-authorizer and the revocation list.
+func (a *App) RateLimitIP(c *gin.Context) {
+	const op = "App RateLimitIP"
+
+	if a.ipRate.Limit == 0 {
+		c.Next()
+		return
+	}
+
+	a.throttle(c, op, "api|ip:"+c.ClientIP(), a.ipRate)
+}
+
+// throttle takes a token from the bucket of key and answers 429 when it is empty.
+func (a *App) throttle(c *gin.Context, op, key string, rate ratelimit.Rate) {
+	ctx := c.Request.Context()
+
+	allowed, retryAfter, err := a.limiter.Take(ctx, key, rate)
+	if err != nil {
+		a.logger.Err(ctx, op, err)
+		c.Next()
+		return
+	}
+
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		a.sendError(c, op, tooManyRequestsErr)
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+// @Summary Transactions
+// @Security ApiKeyAuth
+// @Tags Wallet
+// @Descriotion wallet transaction history, newest first
+// @ID wallet-transactions
+// @Produce json
+// @Param currency query string false "currency code"
+// @Param kind query string false "deposit, withdraw, exchange, transfer_in, transfer_out, fee or adjustment"
+// @Param from query string false "start of the period (RFC3339 or YYYY-MM-DD), inclusive"
+// @Param to query string false "end of the period (RFC3339 or YYYY-MM-DD), exclusive; a date includes the whole day"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} TransactionsResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/wallet/transactions [get]
+func (a *App) Transactions(c *gin.Context) {
+	const op = "App Transactions"
+
+	user, err := currentUser(c)
+	if err != nil {
+		return
+	}
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		a.sendError(c, op, badRequest(err))
+		return
+	}
+
+	a.writeTransactions(c, user, filter)
+}
+
+// writeTransactions answers with a page of the user's transactions selected by the filter.
+func (a *App) writeTransactions(c *gin.Context, user string, filter storages.TransactionFilter) {
+	const op = "App writeTransactions"
+
+	ctx := c.Request.Context()
+
+	filter.UserId = user
+
+	limit := filter.Limit
+	filter.Limit++
+
+	transactions, err := a.storage.GetTransactions(ctx, filter)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	var response TransactionsResponseJSON
+
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		response.NextCursor = encodeCursor(transactions[limit-1].Id)
+	}
+	response.Transactions = transactions
+
+	c.JSON(http.StatusOK, response)
+}
+
+func parseTransactionFilter(c *gin.Context) (storages.TransactionFilter, error) {
+	filter := storages.TransactionFilter{
+		Currency: strings.ToUpper(c.Query("currency")),
+		Kind:     strings.ToLower(c.Query("kind")),
+		Limit:    defaultTransactionsLimit,
+	}
+
+	switch filter.Kind {
+	case "", storages.KindDeposit, storages.KindWithdraw, storages.KindExchange, storages.KindTransferIn, storages.KindTransferOut, storages.KindFee, storages.KindAdjustment:
+	default:
+		return filter, fmt.Errorf("invalid kind")
+	}
+
+	var err error
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = parseTime(from, false); err != nil {
+			return filter, fmt.Errorf("invalid from")
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = parseTime(to, true); err != nil {
+			return filter, fmt.Errorf("invalid to")
+		}
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("from must be before to")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.BeforeId, err = decodeCursor(cursor); err != nil {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxTransactionsLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxTransactionsLimit)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTime accepts RFC3339 timestamps and plain dates. A plain date used as the
+// end of a period is moved to the next midnight so that the whole day is included.
+func parseTime(value string, endOfPeriod bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if endOfPeriod {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if id < 1 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+
+	return id, nil
+}
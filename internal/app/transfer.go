@@ -0,0 +1,246 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/metrics"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	selfTransferErr       = fmt.Errorf("cannot transfer to own wallet")
+	recipientRequiredErr  = fmt.Errorf("recipient is required")
+	recipientAmbiguousErr = fmt.Errorf("give either to_user_id or to_username")
+	recipientNotFoundErr  = fmt.Errorf("recipient wallet not found")
+)
+
+// @Summary Transfer
+// @Security ApiKeyAuth
+// @Tags Wallet
+// @Descriotion transfer money to another wallet, the recipient is given by user id or username
+// @ID transfer-wallet
+// @Accept json
+// @Produce json
+// @Param input body TransferRequest true "recipient, currency and amount"
+// @Param Idempotency-Key header string false "repeat the request safely: the first response is replayed"
+// @Success 200 {object} NewBalanceResponseJSON
+// @Failure 400 {object} ErrResponseJSON
+// @Failure 401 {object} ErrResponseJSON
+// @Failure 404 {object} ErrResponseJSON
+// @Failure 422 {object} ErrResponseJSON
+// @Failure 403 {object} ErrResponseJSON
+// @Failure 423 {object} ErrResponseJSON
+// @Failure 500 {object} ErrResponseJSON
+// @Failure 504 {object} ErrResponseJSON
+// @Router /api/v1/wallet/transfer [post]
+func (a *App) Transfer(c *gin.Context) {
+	const op = "App Transfer"
+
+	ctx := c.Request.Context()
+
+	user, err := currentUser(c)
+	if err != nil {
+		return
+	}
+
+	var request TransferRequest
+
+	if err = bindJSON(c, &request); err != nil {
+		a.sendError(c, op, invalidRequestErr)
+		return
+	}
+
+	idem, err := newIdempotency(c, user)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	request.Currency = strings.ToUpper(request.Currency)
+
+	if request.ToUserId, err = a.recipient(ctx, request); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if request.ToUserId == user {
+		a.sendError(c, op, selfTransferErr)
+		return
+	}
+
+	// The house wallet collects the exchange fees and is not a user's wallet.
+	if request.ToUserId == a.cfg.Exchange.HouseWallet {
+		a.sendError(c, op, recipientNotFoundErr)
+		return
+	}
+
+	currencies, err := a.currencies(ctx)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	currency, err := lookupCurrency(currencies, request.Currency)
+	if err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	if err = validateAmount(currency, request.Amount); err != nil {
+		a.sendError(c, op, err)
+		return
+	}
+
+	var response NewBalanceResponseJSON
+
+	err = a.storage.Transaction(ctx, func(ctx context.Context, tx storages.Tx) error {
+		balances, err := a.lockWallets(ctx, tx, user, request.ToUserId)
+		var lockErr *walletLockError
+		switch {
+		case errors.As(err, &lockErr) && lockErr.userId == request.ToUserId && errors.Is(err, storages.WalletNotFoundErr):
+			return recipientNotFoundErr
+		case err != nil:
+			return err
+		}
+		balance := balances[user]
+		recipient := balances[request.ToUserId]
+
+		if err = idem.check(ctx, tx); err != nil {
+			return err
+		}
+
+		if err = a.checkDebit(ctx, tx, user); err != nil {
+			return err
+		}
+
+		err = a.checkCredit(ctx, tx, request.ToUserId)
+		switch {
+		case errors.Is(err, walletClosedErr):
+			return recipientClosedErr
+		case err != nil:
+			return err
+		}
+
+		if err = a.checkWithdrawal(ctx, tx, user, request.Currency, request.Amount, time.Now()); err != nil {
+			return err
+		}
+
+		debit, err := changeBalance(request.Currency, balance, request.Amount, decimal.NewFromInt(-1))
+		if err != nil {
+			return err
+		}
+
+		credit, err := changeBalance(request.Currency, recipient, request.Amount, decimal.NewFromInt(1))
+		if err != nil {
+			return err
+		}
+
+		if err = tx.UpdateWallet(ctx, user, storages.Balance{request.Currency: balance[request.Currency]}); err != nil {
+			return err
+		}
+
+		if err = tx.UpdateWallet(ctx, request.ToUserId, storages.Balance{request.Currency: recipient[request.Currency]}); err != nil {
+			return err
+		}
+
+		err = tx.AddTransactions(ctx,
+			storages.Transaction{
+				UserId:         user,
+				Kind:           storages.KindTransferOut,
+				Currency:       request.Currency,
+				Amount:         debit,
+				Balance:        balance[request.Currency],
+				CounterpartyId: request.ToUserId,
+			},
+			storages.Transaction{
+				UserId:         request.ToUserId,
+				Kind:           storages.KindTransferIn,
+				Currency:       request.Currency,
+				Amount:         credit,
+				Balance:        recipient[request.Currency],
+				CounterpartyId: user,
+			})
+		if err != nil {
+			return err
+		}
+
+		response = NewBalanceResponseJSON{
+			Message:    "Transfer successful",
+			NewBalance: withEnabledCurrencies(balance, currencies),
+		}
+
+		return idem.save(ctx, tx, http.StatusOK, response)
+	})
+
+	switch {
+	case errors.Is(err, idempotencyReplayErr):
+		idem.writeReplay(c)
+		return
+	case err != nil:
+		a.sendError(c, op, err)
+		return
+	}
+
+	metrics.Operation(storages.KindTransferOut, request.Currency, request.Amount.InexactFloat64())
+
+	c.JSON(http.StatusOK, response)
+}
+
+// recipient finds the user id of the recipient. Usernames are looked up among
+// the wallets, since gw-authorizer cannot resolve them.
+func (a *App) recipient(ctx context.Context, request TransferRequest) (string, error) {
+	switch {
+	case request.ToUserId != "" && request.ToUsername != "":
+		return "", recipientAmbiguousErr
+	case request.ToUserId != "":
+		return request.ToUserId, nil
+	case request.ToUsername == "":
+		return "", recipientRequiredErr
+	}
+
+	user, err := a.storage.GetUserIdByUsername(ctx, request.ToUsername)
+	if errors.Is(err, storages.WalletNotFoundErr) {
+		return "", recipientNotFoundErr
+	}
+
+	return user, err
+}
+
+// walletLockError tells which of the wallets locked together failed.
+type walletLockError struct {
+	userId string
+	err    error
+}
+
+func (e *walletLockError) Error() string {
+	return fmt.Sprintf("wallet %s: %v", e.userId, e.err)
+}
+
+func (e *walletLockError) Unwrap() error {
+	return e.err
+}
+
+// lockWallets locks several wallets in a stable order, so that two opposite
+// transfers between the same wallets cannot deadlock.
+func (a *App) lockWallets(ctx context.Context, tx storages.Tx, users ...string) (map[string]storages.Balance, error) {
+	ordered := append([]string(nil), users...)
+	slices.Sort(ordered)
+
+	result := make(map[string]storages.Balance, len(ordered))
+	for _, user := range ordered {
+		balance, err := tx.GetBalanceForUpdate(ctx, user)
+		if err != nil {
+			return nil, &walletLockError{userId: user, err: err}
+		}
+		result[user] = balance
+	}
+
+	return result, nil
+}
@@ -1,6 +1,11 @@
 package cache
 
+import "time"
+
 type Cache interface {
 	Get(key string) (any, bool)
 	Set(key string, value any)
+	// SetWithTTL stores the value for ttl instead of the cache lifetime.
+	SetWithTTL(key string, value any, ttl time.Duration)
+	Delete(key string)
 }
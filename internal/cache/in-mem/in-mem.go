@@ -5,25 +5,51 @@ import (
 	"time"
 )
 
-func New(lifetime time.Duration) *InMem {
+func New(lifetime time.Duration, hooks Hooks) *InMem {
 
 	inMem := InMem{
 		data:     sync.Map{},
 		lifetime: lifetime,
+		hooks:    hooks,
 	}
 
 	return &inMem
 }
 
 func (i *InMem) Get(key string) (any, bool) {
-	return i.data.Load(key)
+	value, ok := i.data.Load(key)
+	if !ok {
+		i.hooks.miss()
+		return nil, false
+	}
+
+	e := value.(*entry)
+	if !time.Now().Before(e.expiresAt) {
+		i.hooks.miss()
+		return nil, false
+	}
+
+	i.hooks.hit()
+
+	return e.value, true
 }
 
 func (i *InMem) Set(key string, value any) {
-	i.data.Store(key, value)
+	i.SetWithTTL(key, value, i.lifetime)
+}
+
+func (i *InMem) SetWithTTL(key string, value any, ttl time.Duration) {
+	e := &entry{value: value, expiresAt: time.Now().Add(ttl)}
+	i.data.Store(key, e)
 
-	time.AfterFunc(i.lifetime, func() {
-		i.data.Delete(key)
+	// The timer removes only this entry: a value stored again under the same
+	// key has its own timer and must not be dropped early.
+	time.AfterFunc(ttl, func() {
+		i.data.CompareAndDelete(key, e)
 	})
 
 }
+
+func (i *InMem) Delete(key string) {
+	i.data.Delete(key)
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func TestInMem_Lifetime(t *testing.T) {
-	cache := New(3 * time.Second)
+	cache := New(3*time.Second, Hooks{})
 
 	type result struct {
 		key   string
@@ -72,7 +72,7 @@ func TestInMem_Lifetime(t *testing.T) {
 }
 
 func TestInMem_Set_Get(t *testing.T) {
-	cache := New(10 * time.Second)
+	cache := New(10*time.Second, Hooks{})
 
 	type result struct {
 		key   string
@@ -132,3 +132,39 @@ func TestInMem_Set_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestInMem_SetAgain(t *testing.T) {
+	cache := New(200*time.Millisecond, Hooks{})
+
+	cache.Set("1", 1)
+	time.Sleep(150 * time.Millisecond)
+	cache.Set("1", 2)
+	time.Sleep(100 * time.Millisecond)
+
+	if v, ok := cache.Get("1"); !ok || v != 2 {
+		t.Errorf("Result was incorrect, got: %v %t, want: %v %t.", v, ok, 2, true)
+	}
+
+	cache.Delete("1")
+
+	if v, ok := cache.Get("1"); ok {
+		t.Errorf("Result was incorrect, got: %v %t, want: %v %t.", v, ok, nil, false)
+	}
+}
+
+func TestInMem_Hooks(t *testing.T) {
+	var hits, misses int
+	cache := New(time.Minute, Hooks{
+		OnHit:  func() { hits++ },
+		OnMiss: func() { misses++ },
+	})
+
+	cache.Get("key")
+	cache.Set("key", "value")
+	cache.Get("key")
+	cache.Get("key")
+
+	if hits != 2 || misses != 1 {
+		t.Errorf("hits = %d, misses = %d, want 2 and 1", hits, misses)
+	}
+}
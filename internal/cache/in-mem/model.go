@@ -8,4 +8,29 @@ import (
 type InMem struct {
 	data     sync.Map
 	lifetime time.Duration
+	hooks    Hooks
+}
+
+// Hooks are called on every lookup, so the caller can count hits and misses
+// without the cache knowing where they are reported. Nil hooks are skipped.
+type Hooks struct {
+	OnHit  func()
+	OnMiss func()
+}
+
+func (h Hooks) hit() {
+	if h.OnHit != nil {
+		h.OnHit()
+	}
+}
+
+func (h Hooks) miss() {
+	if h.OnMiss != nil {
+		h.OnMiss()
+	}
+}
+
+type entry struct {
+	value     any
+	expiresAt time.Time
 }
@@ -6,33 +6,134 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Postgres  PostgresConfig `env:",prefix=PSQL_" json:",omitempty"`
-	Web       WebConfig      `env:",prefix=WEB_" json:",omitempty"`
-	Exchanger GRPCConfig     `env:",prefix=EXCHANGER_GRPC_SERVER_" json:",omitempty"`
-	Auth      GRPCConfig     `env:",prefix=AUTH_GRPC_SERVER_" json:",omitempty"`
+	Postgres  PostgresConfig  `env:",prefix=PSQL_" json:",omitempty"`
+	Web       WebConfig       `env:",prefix=WEB_" json:",omitempty"`
+	Exchanger GRPCConfig      `env:",prefix=EXCHANGER_GRPC_SERVER_" json:",omitempty"`
+	Auth      GRPCConfig      `env:",prefix=AUTH_GRPC_SERVER_" json:",omitempty"`
+	Exchange  ExchangeConfig  `env:",prefix=EXCHANGE_" json:",omitempty"`
+	Rates     RatesConfig     `env:",prefix=RATES_" json:",omitempty"`
+	JWT       JWTConfig       `env:",prefix=JWT_" json:",omitempty"`
+	Limits    LimitsConfig    `env:",prefix=LIMITS_" json:",omitempty"`
+	RateLimit RateLimitConfig `env:",prefix=RATE_LIMIT_" json:",omitempty"`
+	Tracing   TracingConfig   `env:",prefix=TRACING_" json:",omitempty"`
+	Log       LogConfig       `env:",prefix=LOG_" json:",omitempty"`
+	AccessLog AccessLogConfig `env:",prefix=ACCESS_LOG_" json:",omitempty"`
 }
 
+// PostgresConfig.QueryTimeout limits each query and each transaction as a whole,
+// including the queries made in it, in seconds.
 type PostgresConfig struct {
-	Host        string `env:"HOST,default=localhost" json:",omitempty"`
-	Port        int    `env:"PORT,default=5432" json:",omitempty"`
-	DBName      string `env:"DB_NAME,default=postgres" json:",omitempty"`
-	User        string `env:"USER,default=postgres" json:",omitempty"`
-	Password    string `env:"PASSWORD,default=postgres" json:",omitempty"`
-	SSLMode     string `env:"SSL_MODE,default=disable" json:",omitempty"`
-	ConnTimeout int    `env:"CONN_TIMEOUT,default=5" json:",omitempty"`
+	Host         string `env:"HOST,default=localhost" json:",omitempty"`
+	Port         int    `env:"PORT,default=5432" json:",omitempty"`
+	DBName       string `env:"DB_NAME,default=postgres" json:",omitempty"`
+	User         string `env:"USER,default=postgres" json:",omitempty"`
+	Password     string `env:"PASSWORD,default=postgres" json:",omitempty"`
+	SSLMode      string `env:"SSL_MODE,default=disable" json:",omitempty"`
+	ConnTimeout  int    `env:"CONN_TIMEOUT,default=5" json:",omitempty"`
+	QueryTimeout int    `env:"QUERY_TIMEOUT,default=5" json:",omitempty"`
 }
 
+// WebConfig.TrustedProxies ("10.0.0.0/8,192.168.1.1") may set X-Forwarded-For;
+// without them the client IP is the address of the connection.
+// ShutdownDelay is how long, in seconds, the service reports not ready before it
+// stops accepting connections; ReadinessTimeout limits the dependency checks.
 type WebConfig struct {
-	Host string `env:"HOST,default=localhost" json:",omitempty"`
-	Port int    `env:"PORT,default=80" json:",omitempty"`
+	Host             string `env:"HOST,default=localhost" json:",omitempty"`
+	Port             int    `env:"PORT,default=80" json:",omitempty"`
+	TrustedProxies   string `env:"TRUSTED_PROXIES" json:",omitempty"`
+	ShutdownDelay    int    `env:"SHUTDOWN_DELAY,default=5" json:",omitempty"`
+	ReadinessTimeout int    `env:"READINESS_TIMEOUT,default=2" json:",omitempty"`
 }
 
+// GRPCConfig.Timeout limits each call, in seconds.
 type GRPCConfig struct {
-	Host string `env:"HOST,default=localhost" json:",omitempty"`
-	Port int    `env:"PORT,default=9090" json:",omitempty"`
+	Host    string `env:"HOST,default=localhost" json:",omitempty"`
+	Port    int    `env:"PORT,default=9090" json:",omitempty"`
+	Timeout int    `env:"TIMEOUT,default=3" json:",omitempty"`
+}
+
+// ExchangeConfig holds the quote lifetime and the fee model. Spreads are in
+// percent; PairSpreads ("USD/EUR=0.5,...") override Spread for a pair.
+// MinFee is per target currency and FeeFree is per source currency
+// ("USD=0.10,RUB=10"): exchanges up to the FeeFree amount are not charged.
+type ExchangeConfig struct {
+	QuoteTTL    int    `env:"QUOTE_TTL,default=30" json:",omitempty"`
+	Spread      string `env:"SPREAD,default=0" json:",omitempty"`
+	PairSpreads string `env:"PAIR_SPREADS" json:",omitempty"`
+	MinFee      string `env:"MIN_FEE" json:",omitempty"`
+	FeeFree     string `env:"FEE_FREE" json:",omitempty"`
+	HouseWallet string `env:"HOUSE_WALLET,default=house" json:",omitempty"`
+}
+
+// RatesConfig is in seconds. Exchanges are refused once the rates are older than MaxStaleness.
+type RatesConfig struct {
+	RefreshInterval int `env:"REFRESH_INTERVAL,default=30" json:",omitempty"`
+	Timeout         int `env:"TIMEOUT,default=5" json:",omitempty"`
+	MaxStaleness    int `env:"MAX_STALENESS,default=300" json:",omitempty"`
+}
+
+// JWTConfig enables local token verification when any key source is set.
+// With CheckRevocation the token is also sent to gw-authorizer to check that it was not revoked.
+type JWTConfig struct {
+	PublicKeyFile       string `env:"PUBLIC_KEY_FILE" json:",omitempty"`
+	SharedKey           string `env:"SHARED_KEY" json:"-"`
+	JWKSURL             string `env:"JWKS_URL" json:",omitempty"`
+	JWKSRefreshInterval int    `env:"JWKS_REFRESH_INTERVAL,default=300" json:",omitempty"`
+	CheckRevocation     bool   `env:"CHECK_REVOCATION,default=false" json:",omitempty"`
+	CacheTTL            int    `env:"CACHE_TTL,default=60" json:",omitempty"`
+	AccessTTL           int    `env:"ACCESS_TTL,default=900" json:",omitempty"`
+	RefreshTTL          int    `env:"REFRESH_TTL,default=2592000" json:",omitempty"`
+}
+
+// LimitsConfig holds the limits per wallet tier and currency:
+// "unverified:USD=1000,RUB=100000;verified:USD=10000". A tier or a currency
+// that is not listed has no limit. Daily and monthly totals are counted from
+// midnight UTC and from the first day of the month UTC.
+type LimitsConfig struct {
+	WithdrawSingle  string `env:"WITHDRAW_SINGLE" json:",omitempty"`
+	WithdrawDaily   string `env:"WITHDRAW_DAILY" json:",omitempty"`
+	ExchangeMonthly string `env:"EXCHANGE_MONTHLY" json:",omitempty"`
+}
+
+// RateLimitConfig holds token bucket rates "limit/period", such as "5/1m".
+// Routes ("POST /api/v1/login=5/1m,...") override Default; an empty Default
+// leaves the other routes unlimited. IP is the rate of a client IP over all
+// authenticated routes together, checked before the token; empty is unlimited.
+type RateLimitConfig struct {
+	Default string `env:"DEFAULT,default=120/1m" json:",omitempty"`
+	Routes  string `env:"ROUTES" json:",omitempty"`
+	IP      string `env:"IP,default=600/1m" json:",omitempty"`
+}
+
+// TracingConfig selects the span exporter: none, stdout or otlp (gRPC).
+// SampleRatio is the share of the traces started here that are recorded.
+type TracingConfig struct {
+	Exporter     string  `env:"EXPORTER,default=none" json:",omitempty"`
+	OTLPEndpoint string  `env:"OTLP_ENDPOINT,default=localhost:4317" json:",omitempty"`
+	OTLPInsecure bool    `env:"OTLP_INSECURE,default=true" json:",omitempty"`
+	SampleRatio  float64 `env:"SAMPLE_RATIO,default=1" json:",omitempty"`
+	ServiceName  string  `env:"SERVICE_NAME,default=gw-currency-wallet" json:",omitempty"`
+}
+
+// LogConfig.Format is text or json; Level is debug, info, warn or error.
+type LogConfig struct {
+	Format string `env:"FORMAT,default=text" json:",omitempty"`
+	Level  string `env:"LEVEL,default=info" json:",omitempty"`
+}
+
+// AccessLogConfig.SlowThreshold, in milliseconds, raises the record of a slow
+// request to warn. Only every ProbeSample-th successful probe is logged; 0 logs none.
+type AccessLogConfig struct {
+	SlowThreshold int `env:"SLOW_THRESHOLD,default=1000" json:",omitempty"`
+	ProbeSample   int `env:"PROBE_SAMPLE,default=100" json:",omitempty"`
+}
+
+func (j JWTConfig) LocalVerification() bool {
+	return j.PublicKeyFile != "" || j.SharedKey != "" || j.JWKSURL != ""
 }
 
 func (g GRPCConfig) ConnectionURL() string {
@@ -79,33 +180,135 @@ func (w WebConfig) ConnectionURL() string {
 	return fmt.Sprintf("%s:%d", w.Host, w.Port)
 }
 
+func (w WebConfig) Proxies() []string {
+	var result []string
+
+	for _, proxy := range strings.Split(w.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			result = append(result, proxy)
+		}
+	}
+
+	return result
+}
+
 func LoadConfig(filenames ...string) error {
 	return godotenv.Load(filenames...)
 }
 
-func New() Config {
-	return Config{Postgres: PostgresConfig{
-		Host:        getEnvAsString("PSQL_HOST", "localhost"),
-		Port:        getEnvAsInt("PSQL_PORT", 5432),
-		DBName:      getEnvAsString("PSQL_DB_NAME", "postgres"),
-		User:        getEnvAsString("PSQL_USER", "postgres"),
-		Password:    getEnvAsString("PSQL_PASSWORD", "postgres"),
-		SSLMode:     getEnvAsString("PSQL_SSL_MODE", "disable"),
-		ConnTimeout: getEnvAsInt("PSQL_CONN_TIMEOUT", 60),
+func New() (Config, error) {
+	cfg := Config{Postgres: PostgresConfig{
+		Host:         getEnvAsString("PSQL_HOST", "localhost"),
+		Port:         getEnvAsInt("PSQL_PORT", 5432),
+		DBName:       getEnvAsString("PSQL_DB_NAME", "postgres"),
+		User:         getEnvAsString("PSQL_USER", "postgres"),
+		Password:     getEnvAsString("PSQL_PASSWORD", "postgres"),
+		SSLMode:      getEnvAsString("PSQL_SSL_MODE", "disable"),
+		ConnTimeout:  getEnvAsInt("PSQL_CONN_TIMEOUT", 60),
+		QueryTimeout: getEnvAsInt("PSQL_QUERY_TIMEOUT", 5),
 	},
 		Web: WebConfig{
-			Host: getEnvAsString("WEB_HOST", "localhost"),
-			Port: getEnvAsInt("WEB_PORT", 80),
+			Host:             getEnvAsString("WEB_HOST", "localhost"),
+			Port:             getEnvAsInt("WEB_PORT", 80),
+			TrustedProxies:   getEnvAsString("WEB_TRUSTED_PROXIES", ""),
+			ShutdownDelay:    getEnvAsInt("WEB_SHUTDOWN_DELAY", 5),
+			ReadinessTimeout: getEnvAsInt("WEB_READINESS_TIMEOUT", 2),
 		},
 		Exchanger: GRPCConfig{
-			Host: getEnvAsString("EXCHANGER_HOST", "localhost"),
-			Port: getEnvAsInt("EXCHANGER_PORT", 9090),
+			Host:    getEnvAsString("EXCHANGER_HOST", "localhost"),
+			Port:    getEnvAsInt("EXCHANGER_PORT", 9090),
+			Timeout: getEnvAsInt("EXCHANGER_TIMEOUT", 3),
 		},
 		Auth: GRPCConfig{
-			Host: getEnvAsString("AUTHORIZER_HOST", "localhost"),
-			Port: getEnvAsInt("AUTHORIZER_PORT", 9090),
+			Host:    getEnvAsString("AUTHORIZER_HOST", "localhost"),
+			Port:    getEnvAsInt("AUTHORIZER_PORT", 9090),
+			Timeout: getEnvAsInt("AUTHORIZER_TIMEOUT", 3),
+		},
+		Exchange: ExchangeConfig{
+			QuoteTTL:    getEnvAsInt("EXCHANGE_QUOTE_TTL", 30),
+			Spread:      getEnvAsString("EXCHANGE_SPREAD", "0"),
+			PairSpreads: getEnvAsString("EXCHANGE_PAIR_SPREADS", ""),
+			MinFee:      getEnvAsString("EXCHANGE_MIN_FEE", ""),
+			FeeFree:     getEnvAsString("EXCHANGE_FEE_FREE", ""),
+			HouseWallet: getEnvAsString("EXCHANGE_HOUSE_WALLET", "house"),
+		},
+		Rates: RatesConfig{
+			RefreshInterval: getEnvAsInt("RATES_REFRESH_INTERVAL", 30),
+			Timeout:         getEnvAsInt("RATES_TIMEOUT", 5),
+			MaxStaleness:    getEnvAsInt("RATES_MAX_STALENESS", 300),
+		},
+		JWT: JWTConfig{
+			PublicKeyFile:       getEnvAsString("JWT_PUBLIC_KEY_FILE", ""),
+			SharedKey:           getEnvAsString("JWT_SHARED_KEY", ""),
+			JWKSURL:             getEnvAsString("JWT_JWKS_URL", ""),
+			JWKSRefreshInterval: getEnvAsInt("JWT_JWKS_REFRESH_INTERVAL", 300),
+			CheckRevocation:     getEnvAsBool("JWT_CHECK_REVOCATION", false),
+			CacheTTL:            getEnvAsInt("JWT_CACHE_TTL", 60),
+			AccessTTL:           getEnvAsInt("JWT_ACCESS_TTL", 900),
+			RefreshTTL:          getEnvAsInt("JWT_REFRESH_TTL", 2592000),
+		},
+		Limits: LimitsConfig{
+			WithdrawSingle:  getEnvAsString("LIMITS_WITHDRAW_SINGLE", ""),
+			WithdrawDaily:   getEnvAsString("LIMITS_WITHDRAW_DAILY", ""),
+			ExchangeMonthly: getEnvAsString("LIMITS_EXCHANGE_MONTHLY", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Default: getEnvAsString("RATE_LIMIT_DEFAULT", "120/1m"),
+			Routes:  getEnvAsString("RATE_LIMIT_ROUTES", "POST /api/v1/login=5/1m,POST /api/v1/register=5/1m,POST /api/v1/token/refresh=10/1m"),
+			IP:      getEnvAsString("RATE_LIMIT_IP", "600/1m"),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnvAsString("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnvAsString("TRACING_OTLP_ENDPOINT", "localhost:4317"),
+			OTLPInsecure: getEnvAsBool("TRACING_OTLP_INSECURE", true),
+			SampleRatio:  getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+			ServiceName:  getEnvAsString("TRACING_SERVICE_NAME", "gw-currency-wallet"),
+		},
+		Log: LogConfig{
+			Format: getEnvAsString("LOG_FORMAT", "text"),
+			Level:  getEnvAsString("LOG_LEVEL", "info"),
+		},
+		AccessLog: AccessLogConfig{
+			SlowThreshold: getEnvAsInt("ACCESS_LOG_SLOW_THRESHOLD", 1000),
+			ProbeSample:   getEnvAsInt("ACCESS_LOG_PROBE_SAMPLE", 100),
 		}}
 
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// validate rejects the values the service cannot run with, such as the
+// intervals of background refreshes and the timeouts, which must be positive:
+// a context with a zero timeout fails every query and call at once.
+func (c Config) validate() error {
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"PSQL_QUERY_TIMEOUT", c.Postgres.QueryTimeout},
+		{"WEB_READINESS_TIMEOUT", c.Web.ReadinessTimeout},
+		{"EXCHANGER_TIMEOUT", c.Exchanger.Timeout},
+		{"AUTHORIZER_TIMEOUT", c.Auth.Timeout},
+		{"EXCHANGE_QUOTE_TTL", c.Exchange.QuoteTTL},
+		{"RATES_REFRESH_INTERVAL", c.Rates.RefreshInterval},
+		{"RATES_TIMEOUT", c.Rates.Timeout},
+		{"RATES_MAX_STALENESS", c.Rates.MaxStaleness},
+	}
+
+	for _, field := range positive {
+		if field.value <= 0 {
+			return fmt.Errorf("%s invalid", field.name)
+		}
+	}
+
+	if c.JWT.JWKSURL != "" && c.JWT.JWKSRefreshInterval <= 0 {
+		return fmt.Errorf("JWT_JWKS_REFRESH_INTERVAL invalid")
+	}
+
+	return nil
 }
 
 func getEnvAsString(key string, defaultValue string) string {
@@ -123,3 +326,21 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnvAsString(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnvAsString(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+
+	return defaultValue
+}
@@ -15,6 +15,610 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/admin/wallets/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Admin wallet",
+                "operationId": "admin-wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/adjustments": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Adjust wallet",
+                "operationId": "admin-adjust-wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "currency, signed amount and reason",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.AdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/close": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Close wallet",
+                "operationId": "admin-close-wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "reason",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.AdminActionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/freeze": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Freeze wallet",
+                "operationId": "admin-freeze-wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "reason",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.AdminActionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/tier": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Set wallet tier",
+                "operationId": "admin-set-wallet-tier",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "tier and reason",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.TierRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/transactions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Admin transactions",
+                "operationId": "admin-wallet-transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "currency code",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "deposit, withdraw, exchange, transfer_in, transfer_out, fee or adjustment",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start of the period (RFC3339 or YYYY-MM-DD), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end of the period (RFC3339 or YYYY-MM-DD), exclusive; a date includes the whole day",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 20 by default, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.TransactionsResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/wallets/{user_id}/unfreeze": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Unfreeze wallet",
+                "operationId": "admin-unfreeze-wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "wallet owner",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "reason",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.AdminActionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.WalletResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/exchange": {
             "post": {
                 "security": [
@@ -31,16 +635,127 @@ const docTemplate = `{
                 "tags": [
                     "Exchange"
                 ],
-                "summary": "Exchange",
-                "operationId": "exchange-wallet",
+                "summary": "Exchange",
+                "operationId": "exchange-wallet",
+                "parameters": [
+                    {
+                        "description": "desired currency and amount, or quote_id from /api/v1/exchange/quote",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.ExchangeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "repeat the request safely: the first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.ExchangeResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/exchange/quote": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Exchange"
+                ],
+                "summary": "Quote",
+                "operationId": "exchange-quote",
                 "parameters": [
                     {
-                        "description": "desired currency and amount",
+                        "description": "currencies and amount to exchange",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/app.ExchangeRequest"
+                            "$ref": "#/definitions/app.QuoteRequest"
                         }
                     }
                 ],
@@ -48,7 +763,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/app.ExchangeResponseJSON"
+                            "$ref": "#/definitions/app.QuoteResponseJSON"
                         }
                     },
                     "400": {
@@ -68,6 +783,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
@@ -91,7 +818,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/exchange.Rates"
+                            "$ref": "#/definitions/app.RatesResponseJSON"
                         }
                     },
                     "400": {
@@ -106,8 +833,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
@@ -143,7 +870,231 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/app.TokenResponseJSON"
+                            "$ref": "#/definitions/app.TokenResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/logout": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Logout",
+                "operationId": "logout",
+                "parameters": [
+                    {
+                        "description": "refresh token to revoke",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/app.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.MessageResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/register": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Registration",
+                "operationId": "register-account",
+                "parameters": [
+                    {
+                        "description": "user info",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/app.MessageResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/token/refresh": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Refresh",
+                "operationId": "refresh-token",
+                "parameters": [
+                    {
+                        "description": "refresh token from login or the previous refresh",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/app.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/app.TokenResponseJSON"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/wallet/balance": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Wallet"
+                ],
+                "summary": "Balance",
+                "operationId": "user-balance",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "400": {
@@ -163,12 +1114,23 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
         },
-        "/api/v1/register": {
+        "/api/v1/wallet/deposit": {
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "consumes": [
                     "application/json"
                 ],
@@ -176,26 +1138,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Wallet"
                 ],
-                "summary": "Registration",
-                "operationId": "register-account",
+                "summary": "Deposit",
+                "operationId": "deposit-wallet",
                 "parameters": [
                     {
-                        "description": "user info",
+                        "description": "desired currency and amount",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/app.User"
+                            "$ref": "#/definitions/app.Cash"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "repeat the request safely: the first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/app.MessageResponseJSON"
+                            "$ref": "#/definitions/app.NewBalanceResponseJSON"
                         }
                     },
                     "400": {
@@ -204,16 +1172,40 @@ const docTemplate = `{
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
         },
-        "/api/v1/wallet/balance": {
+        "/api/v1/wallet/transactions": {
             "get": {
                 "security": [
                     {
@@ -226,13 +1218,51 @@ const docTemplate = `{
                 "tags": [
                     "Wallet"
                 ],
-                "summary": "Balance",
-                "operationId": "user-balance",
+                "summary": "Transactions",
+                "operationId": "wallet-transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "currency code",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "deposit, withdraw, exchange, transfer_in, transfer_out, fee or adjustment",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start of the period (RFC3339 or YYYY-MM-DD), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end of the period (RFC3339 or YYYY-MM-DD), exclusive; a date includes the whole day",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 20 by default, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/storages.Balance"
+                            "$ref": "#/definitions/app.TransactionsResponseJSON"
                         }
                     },
                     "400": {
@@ -252,11 +1282,17 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
         },
-        "/api/v1/wallet/deposit": {
+        "/api/v1/wallet/transfer": {
             "post": {
                 "security": [
                     {
@@ -272,17 +1308,23 @@ const docTemplate = `{
                 "tags": [
                     "Wallet"
                 ],
-                "summary": "Deposit",
-                "operationId": "deposit-wallet",
+                "summary": "Transfer",
+                "operationId": "transfer-wallet",
                 "parameters": [
                     {
-                        "description": "desired currency and amount",
+                        "description": "recipient, currency and amount",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/app.Cash"
+                            "$ref": "#/definitions/app.TransferRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "repeat the request safely: the first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -304,11 +1346,41 @@ const docTemplate = `{
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
@@ -340,6 +1412,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/app.Cash"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "repeat the request safely: the first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -361,22 +1439,73 @@ const docTemplate = `{
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/app.ErrResponseJSON"
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/app.ErrResponseJSON"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "app.AdjustmentRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "-10.50"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "duplicate deposit"
+                }
+            }
+        },
+        "app.AdminActionRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "chargeback investigation"
+                }
+            }
+        },
         "app.Cash": {
             "type": "object",
             "properties": {
                 "amount": {
-                    "type": "number"
+                    "type": "string",
+                    "example": "10.50"
                 },
                 "currency": {
                     "type": "string"
@@ -397,20 +1526,117 @@ const docTemplate = `{
         "app.ErrResponseJSON": {
             "type": "object",
             "properties": {
-                "error": {
-                    "type": "string"
+                "code": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/app.ErrorCode"
+                        }
+                    ],
+                    "example": "INSUFFICIENT_FUNDS"
+                },
+                "details": {
+                    "type": "object"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "insufficient funds or invalid amount"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "3f2b8c1e9a4d4e0f8b6a7c5d2e1f0a9b"
                 }
             }
         },
+        "app.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "INVALID_REQUEST",
+                "INVALID_IDEMPOTENCY_KEY",
+                "IDEMPOTENCY_KEY_REUSED",
+                "UNKNOWN_CURRENCY",
+                "SAME_CURRENCY",
+                "INVALID_AMOUNT",
+                "INSUFFICIENT_FUNDS",
+                "SELF_TRANSFER",
+                "LIMIT_EXCEEDED",
+                "UNAUTHENTICATED",
+                "INVALID_CREDENTIALS",
+                "TOKEN_INVALID",
+                "TOKEN_EXPIRED",
+                "TOKEN_REVOKED",
+                "REFRESH_TOKEN_INVALID",
+                "TOKEN_ISSUING_DISABLED",
+                "FORBIDDEN",
+                "USER_EXISTS",
+                "WALLET_NOT_FOUND",
+                "RECIPIENT_NOT_FOUND",
+                "WALLET_FROZEN",
+                "WALLET_CLOSED",
+                "WALLET_STATUS_UNCHANGED",
+                "RECIPIENT_CLOSED",
+                "REASON_REQUIRED",
+                "UNKNOWN_TIER",
+                "INVALID_QUOTE",
+                "QUOTE_NOT_FOUND",
+                "QUOTE_USED",
+                "QUOTE_EXPIRED",
+                "RATES_UNAVAILABLE",
+                "RATE_LIMITED",
+                "UPSTREAM_UNAVAILABLE",
+                "UPSTREAM_TIMEOUT",
+                "INTERNAL"
+            ],
+            "x-enum-varnames": [
+                "CodeInvalidRequest",
+                "CodeInvalidIdempotencyKey",
+                "CodeIdempotencyKeyReused",
+                "CodeUnknownCurrency",
+                "CodeSameCurrency",
+                "CodeInvalidAmount",
+                "CodeInsufficientFunds",
+                "CodeSelfTransfer",
+                "CodeLimitExceeded",
+                "CodeUnauthenticated",
+                "CodeInvalidCredentials",
+                "CodeTokenInvalid",
+                "CodeTokenExpired",
+                "CodeTokenRevoked",
+                "CodeRefreshTokenInvalid",
+                "CodeTokenIssuingDisabled",
+                "CodeForbidden",
+                "CodeUserExists",
+                "CodeWalletNotFound",
+                "CodeRecipientNotFound",
+                "CodeWalletFrozen",
+                "CodeWalletClosed",
+                "CodeWalletStatusUnchanged",
+                "CodeRecipientClosed",
+                "CodeReasonRequired",
+                "CodeUnknownTier",
+                "CodeInvalidQuote",
+                "CodeQuoteNotFound",
+                "CodeQuoteUsed",
+                "CodeQuoteExpired",
+                "CodeRatesUnavailable",
+                "CodeRateLimited",
+                "CodeUpstreamUnavailable",
+                "CodeUpstreamTimeout",
+                "CodeInternal"
+            ]
+        },
         "app.ExchangeRequest": {
             "type": "object",
             "properties": {
                 "amount": {
-                    "type": "number"
+                    "type": "string",
+                    "example": "10.50"
                 },
                 "from_currency": {
                     "type": "string"
                 },
+                "quote_id": {
+                    "type": "string"
+                },
                 "to_currency": {
                     "type": "string"
                 }
@@ -420,13 +1646,19 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "exchange_amount": {
-                    "type": "number"
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "string"
                 },
                 "message": {
                     "type": "string"
                 },
                 "new_balance": {
-                    "$ref": "#/definitions/storages.Balance"
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 }
             }
         },
@@ -445,18 +1677,138 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "new_balance": {
-                    "$ref": "#/definitions/storages.Balance"
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "app.QuoteRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "10.50"
+                },
+                "from_currency": {
+                    "type": "string"
+                },
+                "to_currency": {
+                    "type": "string"
+                }
+            }
+        },
+        "app.QuoteResponseJSON": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "string"
+                },
+                "from_currency": {
+                    "type": "string"
+                },
+                "quote_id": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "string"
+                },
+                "to_amount": {
+                    "type": "string"
+                },
+                "to_currency": {
+                    "type": "string"
+                }
+            }
+        },
+        "app.RatesResponseJSON": {
+            "type": "object",
+            "properties": {
+                "age_seconds": {
+                    "type": "integer"
+                },
+                "rates": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "app.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "app.TierRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "documents checked"
+                },
+                "tier": {
+                    "type": "string",
+                    "example": "verified"
                 }
             }
         },
         "app.TokenResponseJSON": {
             "type": "object",
             "properties": {
+                "refresh_token": {
+                    "type": "string"
+                },
                 "token": {
                     "type": "string"
                 }
             }
         },
+        "app.TransactionsResponseJSON": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "type": "string"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storages.Transaction"
+                    }
+                }
+            }
+        },
+        "app.TransferRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "10.50"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "to_user_id": {
+                    "type": "string"
+                },
+                "to_username": {
+                    "type": "string"
+                }
+            }
+        },
         "app.User": {
             "type": "object",
             "properties": {
@@ -471,28 +1823,51 @@ const docTemplate = `{
                 }
             }
         },
-        "exchange.Rates": {
+        "app.WalletResponseJSON": {
             "type": "object",
             "properties": {
-                "rates": {
+                "balance": {
                     "type": "object",
                     "additionalProperties": {
-                        "type": "number"
+                        "type": "string"
                     }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "tier": {
+                    "type": "string",
+                    "example": "unverified"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "storages.Balance": {
+        "storages.Transaction": {
             "type": "object",
             "properties": {
-                "eur": {
-                    "type": "number"
+                "amount": {
+                    "type": "string"
+                },
+                "balance": {
+                    "type": "string"
                 },
-                "rub": {
-                    "type": "number"
+                "counterparty_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
                 },
-                "usd": {
-                    "type": "number"
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
                 }
             }
         }
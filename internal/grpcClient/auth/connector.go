@@ -1,24 +1,33 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	pb "github.com/HennOgyrchik/proto-jwt-auth/auth"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"gw-currency-wallet/internal/metrics"
+	"time"
 )
 
-func New(grpcServerURL string) *Auth {
+func New(grpcServerURL string, timeout time.Duration) *Auth {
 	return &Auth{
-		url:    grpcServerURL,
-		conn:   nil,
-		client: nil,
+		url:     grpcServerURL,
+		timeout: timeout,
+		conn:    nil,
+		client:  nil,
 	}
 }
 
 func (a *Auth) Run() error {
 	const op = "gRPC Auth New"
 
-	conn, err := grpc.NewClient(a.url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(a.url,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -29,6 +38,30 @@ func (a *Auth) Run() error {
 	return nil
 }
 
+// Check waits until the connection is ready. An idle connection is asked to
+// connect first, since the client connects lazily on the first call.
+func (a *Auth) Check(ctx context.Context) error {
+	const op = "gRPC Auth Check"
+
+	state := a.conn.GetState()
+	if state == connectivity.Idle {
+		a.conn.Connect()
+	}
+
+	for state != connectivity.Ready {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("%s: connection state %s", op, state)
+		}
+
+		if !a.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("%s: connection state %s: %w", op, state, ctx.Err())
+		}
+		state = a.conn.GetState()
+	}
+
+	return nil
+}
+
 func (a *Auth) Stop() error {
 	const op = "gRPC Auth Stop"
 
@@ -6,11 +6,15 @@ import (
 	pb "github.com/HennOgyrchik/proto-jwt-auth/auth"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gw-currency-wallet/internal/grpcClient"
 )
 
 func (a *Auth) CreateUser(ctx context.Context, user CreateUserRequest) (CreateUserResponse, error) {
 	const op = "gRPC Auth CreateUser"
 
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
 	response, err := a.client.CreateUser(ctx, &pb.CreateUserRequest{
 		Username: user.Username,
 		Password: user.Password,
@@ -21,7 +25,7 @@ func (a *Auth) CreateUser(ctx context.Context, user CreateUserRequest) (CreateUs
 	case status.Code(err) == codes.AlreadyExists:
 		return CreateUserResponse{}, UserAlreadyExistsErr
 	case err != nil:
-		return CreateUserResponse{}, fmt.Errorf(op, err)
+		return CreateUserResponse{}, fmt.Errorf("%s: %w", op, grpcClient.WrapErr(err))
 	default:
 		return CreateUserResponse{UserId: response.UserId}, nil
 	}
@@ -31,6 +35,9 @@ func (a *Auth) CreateUser(ctx context.Context, user CreateUserRequest) (CreateUs
 func (a *Auth) Login(ctx context.Context, credentials LoginCredentials) (TokenResponse, error) {
 	const op = "gRPC Auth Login"
 
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
 	token, err := a.client.Login(ctx, &pb.LoginRequest{
 		Username: credentials.Username,
 		Password: credentials.Password,
@@ -40,7 +47,7 @@ func (a *Auth) Login(ctx context.Context, credentials LoginCredentials) (TokenRe
 	case status.Code(err) == codes.InvalidArgument:
 		return TokenResponse{}, InvalidCredentialsErr
 	case err != nil:
-		return TokenResponse{}, fmt.Errorf(op, err)
+		return TokenResponse{}, fmt.Errorf("%s: %w", op, grpcClient.WrapErr(err))
 	default:
 		return TokenResponse{Value: token.Value}, nil
 	}
@@ -50,13 +57,16 @@ func (a *Auth) Login(ctx context.Context, credentials LoginCredentials) (TokenRe
 func (a *Auth) VerifyToken(ctx context.Context, request TokenRequest) (VerifyTokenResponse, error) {
 	const op = "gRPC Auth VerifyToken"
 
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
 	verifyResponse, err := a.client.VerifyToken(ctx, &pb.TokenReuest{UserId: request.UserId, Token: request.Token})
 
 	switch {
 	case status.Code(err) == codes.InvalidArgument:
 		return VerifyTokenResponse{}, InvalidCredentialsErr
 	case err != nil:
-		return VerifyTokenResponse{}, fmt.Errorf(op, err)
+		return VerifyTokenResponse{}, fmt.Errorf("%s: %w", op, grpcClient.WrapErr(err))
 	default:
 		return VerifyTokenResponse{Ok: verifyResponse.Ok}, nil
 	}
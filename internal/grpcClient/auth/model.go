@@ -5,6 +5,7 @@ import (
 	"fmt"
 	pb "github.com/HennOgyrchik/proto-jwt-auth/auth"
 	"google.golang.org/grpc"
+	"time"
 )
 
 type Authorizer interface {
@@ -17,9 +18,10 @@ var UserAlreadyExistsErr = fmt.Errorf("already exists")
 var InvalidCredentialsErr = fmt.Errorf("invalid credentials")
 
 type Auth struct {
-	url    string
-	conn   *grpc.ClientConn
-	client pb.AuthorizationClient
+	url     string
+	timeout time.Duration
+	conn    *grpc.ClientConn
+	client  pb.AuthorizationClient
 }
 
 type CreateUserRequest struct {
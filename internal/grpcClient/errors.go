@@ -0,0 +1,26 @@
+package grpcClient
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var UnavailableErr = fmt.Errorf("service unavailable")
+
+// WrapErr makes a call that ran out of time or was cancelled match
+// context.DeadlineExceeded or context.Canceled, since gRPC reports both as a
+// status, and an unreachable service match UnavailableErr.
+func WrapErr(err error) error {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	case codes.Canceled:
+		return fmt.Errorf("%w: %w", context.Canceled, err)
+	case codes.Unavailable:
+		return fmt.Errorf("%w: %w", UnavailableErr, err)
+	default:
+		return err
+	}
+}
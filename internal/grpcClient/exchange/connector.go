@@ -1,24 +1,33 @@
 package exchange
 
 import (
+	"context"
 	"fmt"
 	pb "github.com/HennOgyrchik/proto-exchange/exchange"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"gw-currency-wallet/internal/metrics"
+	"time"
 )
 
-func New(grpcServerURL string) *Exchange {
+func New(grpcServerURL string, timeout time.Duration) *Exchange {
 	return &Exchange{
-		url:    grpcServerURL,
-		conn:   nil,
-		client: nil,
+		url:     grpcServerURL,
+		timeout: timeout,
+		conn:    nil,
+		client:  nil,
 	}
 }
 
 func (e *Exchange) Run() error {
 	const op = "gRPC Exchange New"
 
-	conn, err := grpc.NewClient(e.url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(e.url,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -29,6 +38,30 @@ func (e *Exchange) Run() error {
 	return nil
 }
 
+// Check waits until the connection is ready. An idle connection is asked to
+// connect first, since the client connects lazily on the first call.
+func (e *Exchange) Check(ctx context.Context) error {
+	const op = "gRPC Exchange Check"
+
+	state := e.conn.GetState()
+	if state == connectivity.Idle {
+		e.conn.Connect()
+	}
+
+	for state != connectivity.Ready {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("%s: connection state %s", op, state)
+		}
+
+		if !e.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("%s: connection state %s: %w", op, state, ctx.Err())
+		}
+		state = e.conn.GetState()
+	}
+
+	return nil
+}
+
 func (e *Exchange) Stop() error {
 	const op = "gRPC Exchange Stop"
 
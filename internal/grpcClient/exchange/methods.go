@@ -4,35 +4,48 @@ import (
 	"context"
 	"fmt"
 	pb "github.com/HennOgyrchik/proto-exchange/exchange"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/grpcClient"
 )
 
 func (e *Exchange) GetExchangeRates(ctx context.Context) (Rates, error) {
 	const op = "gRPC Exchange GetExchangeRates"
 
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
 	var result Rates
 
 	rates, err := e.client.GetExchangeRates(ctx, &pb.Empty{})
 	if err != nil {
-		return result, fmt.Errorf("%s: %w", op, err)
+		return result, fmt.Errorf("%s: %w", op, grpcClient.WrapErr(err))
+	}
+
+	result.Rates = make(map[string]decimal.Decimal, len(rates.Rates))
+	for currency, rate := range rates.Rates {
+		result.Rates[currency] = decimal.NewFromFloat32(rate)
 	}
 
-	return Rates{Rates: rates.Rates}, err
+	return result, nil
 }
 
 func (e *Exchange) GetExchangeRateForCurrency(ctx context.Context, in Currency) (Rate, error) {
 	const op = "gRPC Exchange GetExchangeRateForCurrency"
 
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
 	rate, err := e.client.GetExchangeRateForCurrency(ctx, &pb.CurrencyRequest{
 		FromCurrency: in.FromCurrency,
 		ToCurrency:   in.ToCurrency,
 	})
 	if err != nil {
-		err = fmt.Errorf("%s: %w", op, err)
+		return Rate{}, fmt.Errorf("%s: %w", op, grpcClient.WrapErr(err))
 	}
 
 	return Rate{
 		FromCurrency: rate.FromCurrency,
 		ToCurrency:   rate.ToCurrency,
-		Rate:         rate.Rate,
-	}, err
+		Rate:         decimal.NewFromFloat32(rate.Rate),
+	}, nil
 }
@@ -3,7 +3,9 @@ package exchange
 import (
 	"context"
 	pb "github.com/HennOgyrchik/proto-exchange/exchange"
+	"github.com/shopspring/decimal"
 	"google.golang.org/grpc"
+	"time"
 )
 
 type Exchanger interface {
@@ -12,13 +14,14 @@ type Exchanger interface {
 }
 
 type Exchange struct {
-	url    string
-	conn   *grpc.ClientConn
-	client pb.ExchangeServiceClient
+	url     string
+	timeout time.Duration
+	conn    *grpc.ClientConn
+	client  pb.ExchangeServiceClient
 }
 
 type Rates struct {
-	Rates map[string]float32
+	Rates map[string]decimal.Decimal `swaggertype:"object,string"`
 }
 
 type Currency struct {
@@ -29,5 +32,5 @@ type Currency struct {
 type Rate struct {
 	FromCurrency string
 	ToCurrency   string
-	Rate         float32
+	Rate         decimal.Decimal
 }
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	statusOk   = "ok"
+	statusFail = "fail"
+)
+
+// Check returns nil when the dependency is reachable.
+type Check func(ctx context.Context) error
+
+// Health answers the liveness and readiness probes. The service is ready when
+// every check passes and it is not shutting down.
+type Health struct {
+	timeout      time.Duration
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+type Response struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+type DependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// New creates the probes; each readiness check must finish within timeout.
+func New(timeout time.Duration) *Health {
+	return &Health{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a readiness check. It is not safe to call once the probes are served.
+func (h *Health) Add(name string, check Check) {
+	h.names = append(h.names, name)
+	h.checks[name] = check
+}
+
+// Shutdown makes the service not ready, so that no new traffic is routed to it.
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live answers 200 while the process can serve HTTP.
+func (h *Health) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Status: statusOk})
+}
+
+// Ready runs the checks in parallel and answers 503 if any fails.
+func (h *Health) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	response := Response{Status: statusOk, Checks: make(map[string]DependencyStatus, len(h.names))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, name := range h.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status := DependencyStatus{Status: statusOk}
+			if err := h.checks[name](ctx); err != nil {
+				status = DependencyStatus{Status: statusFail, Error: err.Error()}
+			}
+
+			mu.Lock()
+			response.Checks[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range response.Checks {
+		if status.Status != statusOk {
+			response.Status = statusFail
+		}
+	}
+
+	if h.shuttingDown.Load() {
+		response.Status = "shutting down"
+	}
+
+	code := http.StatusOK
+	if response.Status != statusOk {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, response)
+}
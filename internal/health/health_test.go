@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth_Ready(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return fmt.Errorf("connection refused") }
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		shutdown   bool
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "все зависимости доступны",
+			checks:     map[string]Check{"postgres": ok, "exchanger": ok},
+			wantStatus: http.StatusOK,
+			wantBody:   statusOk,
+		},
+		{
+			name:       "одна зависимость недоступна",
+			checks:     map[string]Check{"postgres": ok, "exchanger": fail},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   statusFail,
+		},
+		{
+			name:       "остановка сервиса",
+			checks:     map[string]Check{"postgres": ok},
+			shutdown:   true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "shutting down",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(time.Second)
+			for name, check := range tt.checks {
+				h.Add(name, check)
+			}
+			if tt.shutdown {
+				h.Shutdown()
+			}
+
+			router := gin.New()
+			router.GET("/readyz", h.Ready)
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			var got Response
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantBody || len(got.Checks) != len(tt.checks) {
+				t.Errorf("response = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStater is a pgx connection pool, such as postgres.PSQL.
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+type poolCollector struct {
+	pool PoolStater
+
+	acquired    *prometheus.Desc
+	idle        *prometheus.Desc
+	total       *prometheus.Desc
+	max         *prometheus.Desc
+	acquires    *prometheus.Desc
+	waits       *prometheus.Desc
+	waitSeconds *prometheus.Desc
+}
+
+// RegisterPool exports the pool statistics, read on every scrape.
+func RegisterPool(pool PoolStater) error {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return prometheus.Register(&poolCollector{
+		pool:        pool,
+		acquired:    desc("acquired_connections", "Connections in use."),
+		idle:        desc("idle_connections", "Idle connections."),
+		total:       desc("total_connections", "Open connections."),
+		max:         desc("max_connections", "Maximum size of the pool."),
+		acquires:    desc("acquires_total", "Connections acquired from the pool."),
+		waits:       desc("empty_acquires_total", "Acquires that waited for a connection because the pool was empty."),
+		waitSeconds: desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+	})
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{p.acquired, p.idle, p.total, p.max, p.acquires, p.waits, p.waitSeconds} {
+		ch <- desc
+	}
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := p.pool.Stat()
+	if stat == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(p.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(p.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(p.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.waits, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.waitSeconds, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
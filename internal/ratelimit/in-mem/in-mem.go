@@ -0,0 +1,55 @@
+package in_mem
+
+import (
+	"context"
+	"gw-currency-wallet/internal/ratelimit"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+func New() *InMem {
+	return &InMem{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (i *InMem) Take(_ context.Context, key string, rate ratelimit.Rate) (bool, time.Duration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	i.sweep(now)
+
+	b, ok := i.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Limit), updatedAt: now, full: rate.Period}
+		i.buckets[key] = b
+	}
+
+	b.tokens = min(float64(rate.Limit), b.tokens+float64(now.Sub(b.updatedAt))/float64(rate.Interval()))
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(rate.Interval())), nil
+	}
+
+	b.tokens--
+
+	return true, 0, nil
+}
+
+// sweep drops the buckets that have refilled: a full bucket is the same as a missing one.
+func (i *InMem) sweep(now time.Time) {
+	if now.Sub(i.lastSweep) < sweepInterval {
+		return
+	}
+	i.lastSweep = now
+
+	for key, b := range i.buckets {
+		if now.Sub(b.updatedAt) >= b.full {
+			delete(i.buckets, key)
+		}
+	}
+}
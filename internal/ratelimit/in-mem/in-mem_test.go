@@ -0,0 +1,48 @@
+package in_mem
+
+import (
+	"context"
+	"gw-currency-wallet/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestInMem_Take(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := New()
+	store.now = func() time.Time { return now }
+	rate := ratelimit.Rate{Limit: 2, Period: time.Minute}
+
+	take := func(key string) (bool, time.Duration) {
+		ok, retryAfter, err := store.Take(context.Background(), key, rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, retryAfter
+	}
+
+	tests := []struct {
+		name           string
+		key            string
+		advance        time.Duration
+		want           bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "первый запрос", key: "a", want: true},
+		{name: "второй запрос", key: "a", want: true},
+		{name: "бакет пуст", key: "a", want: false, wantRetryAfter: 30 * time.Second},
+		{name: "другой ключ", key: "b", want: true},
+		{name: "через 10 секунд", key: "a", advance: 10 * time.Second, want: false, wantRetryAfter: 20 * time.Second},
+		{name: "токен восстановился", key: "a", advance: 20 * time.Second, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+
+			got, retryAfter := take(tt.key)
+			if got != tt.want || retryAfter != tt.wantRetryAfter {
+				t.Errorf("Take() = %v, %v, want %v, %v", got, retryAfter, tt.want, tt.wantRetryAfter)
+			}
+		})
+	}
+}
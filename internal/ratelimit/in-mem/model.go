@@ -0,0 +1,20 @@
+package in_mem
+
+import (
+	"sync"
+	"time"
+)
+
+type InMem struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// bucket stores the tokens left at updatedAt; the refill is computed on Take.
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	full      time.Duration
+}
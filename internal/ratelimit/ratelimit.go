@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store keeps token buckets. The in-memory store limits a single instance;
+// several instances behind a balancer need a shared store.
+type Store interface {
+	// Take removes a token from the bucket of key. When the bucket is empty it
+	// returns false and the time until the next token.
+	Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error)
+}
+
+// Rate is a token bucket of Limit tokens refilled evenly over Period.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Interval is the time to refill one token.
+func (r Rate) Interval() time.Duration {
+	return r.Period / time.Duration(r.Limit)
+}
+
+// Limits are the rates per route ("POST /api/v1/login") and the rate of the
+// other routes. A zero Default leaves the other routes unlimited.
+type Limits struct {
+	Default Rate
+	Routes  map[string]Rate
+}
+
+func (l Limits) For(route string) (Rate, bool) {
+	if rate, ok := l.Routes[route]; ok {
+		return rate, true
+	}
+
+	return l.Default, l.Default.Limit > 0
+}
+
+// ParseLimits parses the default rate and "METHOD /path=rate,METHOD /path=rate".
+func ParseLimits(defaultRate, routes string) (Limits, error) {
+	result := Limits{Routes: make(map[string]Rate)}
+
+	var err error
+
+	if strings.TrimSpace(defaultRate) != "" {
+		if result.Default, err = ParseRate(defaultRate); err != nil {
+			return Limits{}, err
+		}
+	}
+
+	for _, item := range strings.Split(routes, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		route, rawRate, ok := strings.Cut(item, "=")
+		if !ok {
+			return Limits{}, fmt.Errorf("invalid item %q", item)
+		}
+
+		rate, err := ParseRate(rawRate)
+		if err != nil {
+			return Limits{}, err
+		}
+
+		result.Routes[strings.Join(strings.Fields(route), " ")] = rate
+	}
+
+	return result, nil
+}
+
+// ParseRate parses "limit/period", for example "5/1m".
+func ParseRate(value string) (Rate, error) {
+	rawLimit, rawPeriod, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q", value)
+	}
+
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 1 {
+		return Rate{}, fmt.Errorf("invalid rate %q", value)
+	}
+
+	period, err := time.ParseDuration(rawPeriod)
+	if err != nil || period <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q", value)
+	}
+
+	return Rate{Limit: limit, Period: period}, nil
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("120/1m", "POST  /api/v1/login=5/1m, GET /api/v1/wallet/balance=10/1s")
+	if err != nil {
+		t.Fatalf("ParseLimits() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		route string
+		want  Rate
+	}{
+		{name: "маршрут из конфигурации", route: "POST /api/v1/login", want: Rate{Limit: 5, Period: time.Minute}},
+		{name: "второй маршрут", route: "GET /api/v1/wallet/balance", want: Rate{Limit: 10, Period: time.Second}},
+		{name: "лимит по умолчанию", route: "POST /api/v1/exchange", want: Rate{Limit: 120, Period: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := limits.For(tt.route); !ok || got != tt.want {
+				t.Errorf("For() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := (Limits{}).For("POST /api/v1/exchange"); ok {
+		t.Errorf("For() without default rate limited the route")
+	}
+
+	for _, value := range []string{"5", "0/1m", "5/0s", "5/minute"} {
+		if _, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) expected error", value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package rates
+
+import (
+	"fmt"
+	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/pkg/logs"
+	"sync"
+	"time"
+)
+
+var StaleRatesErr = fmt.Errorf("exchange rates are stale")
+
+// Provider keeps the last good exchange rates and refreshes them in the background.
+type Provider struct {
+	exchanger    exchange.Exchanger
+	interval     time.Duration
+	timeout      time.Duration
+	maxStaleness time.Duration
+	logger       *logs.Log
+
+	mu        sync.RWMutex
+	rates     exchange.Rates
+	updatedAt time.Time
+}
+
+// Snapshot is the rates together with the time they were received.
+type Snapshot struct {
+	Rates     exchange.Rates
+	UpdatedAt time.Time
+}
+
+// Age is how old the rates are at now.
+func (s Snapshot) Age(now time.Time) time.Duration {
+	return now.Sub(s.UpdatedAt)
+}
@@ -0,0 +1,83 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/grpcClient/exchange"
+	"gw-currency-wallet/pkg/logs"
+	"time"
+)
+
+// New creates a provider that refreshes rates every interval and treats them as
+// stale after maxStaleness. A single refresh is limited by timeout.
+func New(exchanger exchange.Exchanger, interval, timeout, maxStaleness time.Duration, logger *logs.Log) *Provider {
+	return &Provider{
+		exchanger:    exchanger,
+		interval:     interval,
+		timeout:      timeout,
+		maxStaleness: maxStaleness,
+		logger:       logger,
+	}
+}
+
+// Run refreshes rates right away and then on every interval until ctx is done.
+// A failed refresh keeps the previous rates.
+func (p *Provider) Run(ctx context.Context) {
+	const op = "Rates Run"
+
+	if err := p.refresh(ctx); err != nil {
+		p.logger.Err(ctx, op, err)
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.refresh(ctx); err != nil {
+				p.logger.Err(ctx, op, err)
+			}
+		}
+	}
+}
+
+func (p *Provider) refresh(ctx context.Context) error {
+	const op = "Rates refresh"
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	rates, err := p.exchanger.GetExchangeRates(ctxWithTimeout)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.mu.Lock()
+	p.rates = rates
+	p.updatedAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Last returns the last good rates whatever their age. ok is false until the
+// first successful refresh.
+func (p *Provider) Last() (snapshot Snapshot, ok bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return Snapshot{Rates: p.rates, UpdatedAt: p.updatedAt}, !p.updatedAt.IsZero()
+}
+
+// Fresh returns the last good rates unless they are older than the maximum staleness.
+func (p *Provider) Fresh() (Snapshot, error) {
+	snapshot, ok := p.Last()
+	if !ok || snapshot.Age(time.Now()) > p.maxStaleness {
+		return Snapshot{}, StaleRatesErr
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,56 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/grpcClient/exchange"
+	"testing"
+	"time"
+)
+
+type fakeExchanger struct {
+	exchange.Exchanger
+	rates exchange.Rates
+	err   error
+}
+
+func (f *fakeExchanger) GetExchangeRates(context.Context) (exchange.Rates, error) {
+	return f.rates, f.err
+}
+
+func TestProvider_Fresh(t *testing.T) {
+	exchanger := &fakeExchanger{rates: exchange.Rates{Rates: map[string]decimal.Decimal{"USD": decimal.NewFromInt(1)}}}
+	provider := New(exchanger, time.Minute, time.Second, 5*time.Minute, nil)
+
+	if _, err := provider.Fresh(); !errors.Is(err, StaleRatesErr) {
+		t.Fatalf("Fresh() before refresh error = %v, want %v", err, StaleRatesErr)
+	}
+
+	if err := provider.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+
+	exchanger.err = errors.New("exchanger is down")
+	if err := provider.refresh(context.Background()); err == nil {
+		t.Fatalf("refresh() error = nil, want error")
+	}
+
+	snapshot, err := provider.Fresh()
+	if err != nil {
+		t.Fatalf("Fresh() after failed refresh error = %v", err)
+	}
+	if !snapshot.Rates.Rates["USD"].Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Fresh() rates = %v, want last good rates", snapshot.Rates.Rates)
+	}
+
+	provider.updatedAt = time.Now().Add(-6 * time.Minute)
+
+	if _, err = provider.Fresh(); !errors.Is(err, StaleRatesErr) {
+		t.Errorf("Fresh() with old rates error = %v, want %v", err, StaleRatesErr)
+	}
+
+	if _, ok := provider.Last(); !ok {
+		t.Errorf("Last() with old rates ok = false, want true")
+	}
+}
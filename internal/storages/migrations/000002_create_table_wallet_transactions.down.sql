@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_transactions;
+DROP FUNCTION IF EXISTS wallet_transactions_append_only;
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+                                id bigserial NOT NULL,
+                                user_id text NOT NULL,
+                                kind text NOT NULL,
+                                currency text NOT NULL,
+                                amount numeric NOT NULL,
+                                balance numeric NOT NULL,
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT wallet_transactions_pkey PRIMARY KEY (id),
+                                CONSTRAINT wallet_transactions_user_id_fkey FOREIGN KEY (user_id) REFERENCES wallets (user_id),
+                                CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange'))
+);
+
+CREATE INDEX IF NOT EXISTS wallet_transactions_user_id_idx ON wallet_transactions (user_id, id);
+
+CREATE OR REPLACE FUNCTION wallet_transactions_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'wallet_transactions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_transactions_append_only
+    BEFORE UPDATE OR DELETE ON wallet_transactions
+    FOR EACH ROW EXECUTE FUNCTION wallet_transactions_append_only();
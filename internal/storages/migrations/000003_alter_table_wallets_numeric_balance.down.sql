@@ -0,0 +1,8 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS cash json NULL;
+
+UPDATE wallets SET cash = json_build_object('USD', usd::float4, 'RUB', rub::float4, 'EUR', eur::float4);
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS usd,
+    DROP COLUMN IF EXISTS rub,
+    DROP COLUMN IF EXISTS eur;
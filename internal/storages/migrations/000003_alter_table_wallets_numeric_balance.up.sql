@@ -0,0 +1,12 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS usd numeric NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rub numeric NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS eur numeric NOT NULL DEFAULT 0;
+
+UPDATE wallets
+SET usd = round(coalesce((cash ->> 'USD')::numeric, 0), 2),
+    rub = round(coalesce((cash ->> 'RUB')::numeric, 0), 2),
+    eur = round(coalesce((cash ->> 'EUR')::numeric, 0), 2)
+WHERE cash IS NOT NULL;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS cash;
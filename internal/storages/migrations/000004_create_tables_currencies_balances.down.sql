@@ -0,0 +1,14 @@
+ALTER TABLE wallet_transactions DROP CONSTRAINT IF EXISTS wallet_transactions_currency_fkey;
+
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS usd numeric NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rub numeric NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS eur numeric NOT NULL DEFAULT 0;
+
+UPDATE wallets w
+SET usd = coalesce((SELECT amount FROM balances b WHERE b.user_id = w.user_id AND b.currency = 'USD'), 0),
+    rub = coalesce((SELECT amount FROM balances b WHERE b.user_id = w.user_id AND b.currency = 'RUB'), 0),
+    eur = coalesce((SELECT amount FROM balances b WHERE b.user_id = w.user_id AND b.currency = 'EUR'), 0);
+
+DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS currencies;
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS currencies (
+                                code text NOT NULL,
+                                minor_units smallint NOT NULL,
+                                enabled boolean NOT NULL DEFAULT true,
+                                CONSTRAINT currencies_pkey PRIMARY KEY (code),
+                                CONSTRAINT currencies_code_check CHECK (code ~ '^[A-Z]{3}$'),
+                                CONSTRAINT currencies_minor_units_check CHECK (minor_units BETWEEN 0 AND 8)
+);
+
+INSERT INTO currencies (code, minor_units) VALUES ('USD', 2), ('RUB', 2), ('EUR', 2) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS balances (
+                                user_id text NOT NULL,
+                                currency text NOT NULL,
+                                amount numeric NOT NULL DEFAULT 0,
+                                CONSTRAINT balances_pkey PRIMARY KEY (user_id, currency),
+                                CONSTRAINT balances_user_id_fkey FOREIGN KEY (user_id) REFERENCES wallets (user_id),
+                                CONSTRAINT balances_currency_fkey FOREIGN KEY (currency) REFERENCES currencies (code),
+                                CONSTRAINT balances_amount_check CHECK (amount >= 0)
+);
+
+INSERT INTO balances (user_id, currency, amount)
+SELECT user_id, 'USD', usd FROM wallets WHERE usd <> 0
+UNION ALL
+SELECT user_id, 'RUB', rub FROM wallets WHERE rub <> 0
+UNION ALL
+SELECT user_id, 'EUR', eur FROM wallets WHERE eur <> 0
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS usd,
+    DROP COLUMN IF EXISTS rub,
+    DROP COLUMN IF EXISTS eur;
+
+ALTER TABLE wallet_transactions
+    ADD CONSTRAINT wallet_transactions_currency_fkey FOREIGN KEY (currency) REFERENCES currencies (code);
@@ -0,0 +1,6 @@
+-- wallet_transactions is append-only, so the transfer rows stay; NOT VALID keeps
+-- them and applies the old kinds to new rows only.
+ALTER TABLE wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange')) NOT VALID,
+    DROP COLUMN IF EXISTS counterparty_id;
@@ -0,0 +1,4 @@
+ALTER TABLE wallet_transactions
+    ADD COLUMN IF NOT EXISTS counterparty_id text NULL,
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange', 'transfer_in', 'transfer_out'));
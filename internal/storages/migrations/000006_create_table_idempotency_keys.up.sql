@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+                                user_id text NOT NULL,
+                                key text NOT NULL,
+                                request_hash text NOT NULL,
+                                status_code integer NOT NULL,
+                                response jsonb NOT NULL,
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT idempotency_keys_pkey PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
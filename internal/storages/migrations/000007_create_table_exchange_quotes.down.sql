@@ -0,0 +1 @@
+DROP TABLE IF EXISTS exchange_quotes;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS exchange_quotes (
+                                id uuid NOT NULL DEFAULT gen_random_uuid(),
+                                user_id text NOT NULL,
+                                from_currency text NOT NULL,
+                                to_currency text NOT NULL,
+                                amount numeric NOT NULL,
+                                rate numeric NOT NULL,
+                                to_amount numeric NOT NULL,
+                                expires_at timestamptz NOT NULL,
+                                used_at timestamptz NULL,
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT exchange_quotes_pkey PRIMARY KEY (id),
+                                CONSTRAINT exchange_quotes_user_id_fkey FOREIGN KEY (user_id) REFERENCES wallets (user_id),
+                                CONSTRAINT exchange_quotes_from_currency_fkey FOREIGN KEY (from_currency) REFERENCES currencies (code),
+                                CONSTRAINT exchange_quotes_to_currency_fkey FOREIGN KEY (to_currency) REFERENCES currencies (code)
+);
+
+CREATE INDEX IF NOT EXISTS exchange_quotes_expires_at_idx ON exchange_quotes (expires_at);
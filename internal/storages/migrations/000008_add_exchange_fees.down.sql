@@ -0,0 +1,7 @@
+-- wallet_transactions is append-only, so the fee rows stay; NOT VALID keeps
+-- them and applies the old kinds to new rows only.
+ALTER TABLE wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange', 'transfer_in', 'transfer_out')) NOT VALID;
+ALTER TABLE exchange_quotes
+    DROP COLUMN IF EXISTS fee;
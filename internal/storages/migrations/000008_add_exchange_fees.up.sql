@@ -0,0 +1,5 @@
+ALTER TABLE exchange_quotes
+    ADD COLUMN IF NOT EXISTS fee numeric NOT NULL DEFAULT 0;
+ALTER TABLE wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange', 'transfer_in', 'transfer_out', 'fee'));
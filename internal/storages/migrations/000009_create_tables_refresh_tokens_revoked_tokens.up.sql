@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+                                token_hash text NOT NULL,
+                                user_id text NOT NULL,
+                                roles text[] NOT NULL DEFAULT '{}',
+                                expires_at timestamptz NOT NULL,
+                                used_at timestamptz NULL,
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT refresh_tokens_pkey PRIMARY KEY (token_hash)
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+                                token_id text NOT NULL,
+                                expires_at timestamptz NOT NULL,
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT revoked_tokens_pkey PRIMARY KEY (token_id)
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
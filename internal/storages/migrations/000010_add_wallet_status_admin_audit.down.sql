@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS admin_audit;
+DROP FUNCTION IF EXISTS admin_audit_append_only();
+
+-- wallet_transactions is append-only, so the adjustment rows stay; NOT VALID
+-- keeps them and applies the old kinds to new rows only.
+ALTER TABLE wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange', 'transfer_in', 'transfer_out', 'fee')) NOT VALID;
+
+ALTER TABLE wallets
+    DROP CONSTRAINT IF EXISTS wallets_status_check,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,30 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'active',
+    ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen'));
+
+ALTER TABLE wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check,
+    ADD CONSTRAINT wallet_transactions_kind_check CHECK (kind IN ('deposit', 'withdraw', 'exchange', 'transfer_in', 'transfer_out', 'fee', 'adjustment'));
+
+CREATE TABLE IF NOT EXISTS admin_audit (
+                                id bigserial NOT NULL,
+                                actor_id text NOT NULL,
+                                action text NOT NULL,
+                                user_id text NOT NULL,
+                                reason text NOT NULL DEFAULT '',
+                                details jsonb NOT NULL DEFAULT '{}',
+                                created_at timestamptz NOT NULL DEFAULT now(),
+                                CONSTRAINT admin_audit_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_user_id_idx ON admin_audit (user_id, id);
+
+CREATE OR REPLACE FUNCTION admin_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER admin_audit_append_only
+    BEFORE UPDATE OR DELETE ON admin_audit
+    FOR EACH ROW EXECUTE FUNCTION admin_audit_append_only();
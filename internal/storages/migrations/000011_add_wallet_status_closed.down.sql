@@ -0,0 +1,5 @@
+UPDATE wallets SET status = 'frozen' WHERE status = 'closed';
+
+ALTER TABLE wallets
+    DROP CONSTRAINT IF EXISTS wallets_status_check,
+    ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen'));
@@ -0,0 +1,3 @@
+ALTER TABLE wallets
+    DROP CONSTRAINT IF EXISTS wallets_status_check,
+    ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen', 'closed'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS wallet_transactions_user_id_currency_created_at_idx;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS tier;
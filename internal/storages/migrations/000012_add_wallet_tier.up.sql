@@ -0,0 +1,4 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS tier text NOT NULL DEFAULT 'unverified';
+
+CREATE INDEX IF NOT EXISTS wallet_transactions_user_id_currency_created_at_idx ON wallet_transactions (user_id, currency, created_at);
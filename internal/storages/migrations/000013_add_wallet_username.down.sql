@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS wallets_username_key;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS username;
@@ -0,0 +1,4 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS username text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS wallets_username_key ON wallets (username);
@@ -22,12 +22,13 @@ func New() *PSQL {
 	}
 }
 
-func (p *PSQL) Start(ctx context.Context, url string, timeout time.Duration, migrationsPath string) error {
+// Start connects within connTimeout; queryTimeout then limits every query.
+func (p *PSQL) Start(ctx context.Context, url string, connTimeout, queryTimeout time.Duration, migrationsPath string) error {
 	const op = "PSQL Start"
 
-	p.timeout = timeout
+	p.timeout = queryTimeout
 
-	ctxTimeout, cancel := context.WithTimeout(ctx, p.timeout)
+	ctxTimeout, cancel := context.WithTimeout(ctx, connTimeout)
 	defer cancel()
 
 	pool, err := pgxpool.Connect(ctxTimeout, url)
@@ -62,6 +63,25 @@ func doMigrate(dbURL, migrationsPath string) error {
 	return nil
 }
 
+func (p *PSQL) Ping(ctx context.Context) error {
+	const op = "PSQL Ping"
+
+	if err := p.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Stat is the connection pool statistics, nil before Start.
+func (p *PSQL) Stat() *pgxpool.Stat {
+	if p.pool == nil {
+		return nil
+	}
+
+	return p.pool.Stat()
+}
+
 func (p *PSQL) Stop() {
 	if p.pool != nil {
 		p.pool.Close()
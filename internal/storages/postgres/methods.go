@@ -2,18 +2,50 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
 	"gw-currency-wallet/internal/storages"
+	"strings"
+	"time"
 )
 
+func (p *PSQL) GetCurrencies(ctx context.Context) ([]storages.Currency, error) {
+	const op = "PSQL GetCurrencies"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	rows, err := p.pool.Query(ctxWithTimeout, "select code, minor_units, enabled from currencies order by code")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var result []storages.Currency
+	for rows.Next() {
+		var currency storages.Currency
+		if err = rows.Scan(&currency.Code, &currency.MinorUnits, &currency.Enabled); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		result = append(result, currency)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
 func (p *PSQL) GetBalance(ctx context.Context, user string) (storages.Balance, error) {
 	const op = "PSQL GetBalance"
 
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, p.timeout)
-	defer cancel()
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
 
-	var result storages.Balance
-	err := p.pool.QueryRow(ctxWithTimeout, "select cash from wallets where user_id = $1", user).Scan(&result)
+	result, err := getBalance(ctxWithTimeout, p.pool, user, "")
 	if err != nil {
 		err = fmt.Errorf("%s: %w", op, err)
 	}
@@ -21,14 +53,73 @@ func (p *PSQL) GetBalance(ctx context.Context, user string) (storages.Balance, e
 	return result, err
 }
 
-func (p *PSQL) NewWallet(ctx context.Context, id string) error {
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// getBalance reads the wallet row, optionally with a locking clause, and its balances.
+func getBalance(ctx context.Context, q querier, user, lock string) (storages.Balance, error) {
+	var exists int
+	err := q.QueryRow(ctx, "select 1 from wallets where user_id = $1 "+lock, user).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storages.WalletNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx, "select currency, amount from balances where user_id = $1", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(storages.Balance)
+	for rows.Next() {
+		var (
+			currency string
+			amount   decimal.Decimal
+		)
+		if err = rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		result[currency] = amount
+	}
+
+	return result, rows.Err()
+}
+
+func (p *PSQL) GetWallet(ctx context.Context, user string) (storages.Wallet, error) {
+	const op = "PSQL GetWallet"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	wallet := storages.Wallet{UserId: user}
+
+	err := p.pool.QueryRow(ctxWithTimeout, "select status, tier from wallets where user_id = $1", user).Scan(&wallet.Status, &wallet.Tier)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return storages.Wallet{}, storages.WalletNotFoundErr
+	case err != nil:
+		return storages.Wallet{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if wallet.Balance, err = getBalance(ctxWithTimeout, p.pool, user, ""); err != nil {
+		return storages.Wallet{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return wallet, nil
+}
+
+func (p *PSQL) NewWallet(ctx context.Context, id, username string) error {
 	const op = "PSQL NewWallet"
 
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, p.timeout)
-	defer cancel()
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
 
-	var newWallet storages.Balance
-	_, err := p.pool.Exec(ctxWithTimeout, "insert into wallets (user_id, cash) values($1,$2)", id, newWallet)
+	_, err := p.pool.Exec(ctxWithTimeout, "insert into wallets (user_id, username) values($1, nullif($2, ''))", id, username)
 	if err != nil {
 		err = fmt.Errorf("%s: %w", op, err)
 	}
@@ -36,16 +127,297 @@ func (p *PSQL) NewWallet(ctx context.Context, id string) error {
 	return err
 }
 
-func (p *PSQL) UpdateWallet(ctx context.Context, user string, balance storages.Balance) error {
-	const op = "PSQL UpdateWallet"
+// SetWalletUsername records the username of the wallet owner, so that others
+// can find the wallet by it.
+func (p *PSQL) SetWalletUsername(ctx context.Context, id, username string) error {
+	const op = "PSQL SetWalletUsername"
 
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, p.timeout)
-	defer cancel()
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
 
-	_, err := p.pool.Exec(ctxWithTimeout, "update wallets set cash = $1 where user_id = $2", balance, user)
+	_, err := p.pool.Exec(ctxWithTimeout, "update wallets set username = $2 where user_id = $1 and username is distinct from $2", id, username)
 	if err != nil {
 		err = fmt.Errorf("%s: %w", op, err)
 	}
 
 	return err
 }
+
+func (p *PSQL) GetUserIdByUsername(ctx context.Context, username string) (string, error) {
+	const op = "PSQL GetUserIdByUsername"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	var id string
+
+	err := p.pool.QueryRow(ctxWithTimeout, "select user_id from wallets where username = $1", username).Scan(&id)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return "", storages.WalletNotFoundErr
+	case err != nil:
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// EnsureWallet creates the wallet unless it already exists.
+func (p *PSQL) EnsureWallet(ctx context.Context, id string) error {
+	const op = "PSQL EnsureWallet"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	_, err := p.pool.Exec(ctxWithTimeout, "insert into wallets (user_id) values($1) on conflict (user_id) do nothing", id)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (p *PSQL) GetTransactions(ctx context.Context, filter storages.TransactionFilter) ([]storages.Transaction, error) {
+	const op = "PSQL GetTransactions"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	conditions := []string{"user_id = $1"}
+	args := []any{filter.UserId}
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Currency != "" {
+		addCondition("currency = $%d", filter.Currency)
+	}
+	if filter.Kind != "" {
+		addCondition("kind = $%d", filter.Kind)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if filter.BeforeId > 0 {
+		addCondition("id < $%d", filter.BeforeId)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf("select id, user_id, kind, currency, amount, balance, coalesce(counterparty_id, ''), created_at from wallet_transactions where %s order by id desc limit $%d",
+		strings.Join(conditions, " and "), len(args))
+
+	rows, err := p.pool.Query(ctxWithTimeout, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	result := make([]storages.Transaction, 0, filter.Limit)
+	for rows.Next() {
+		var tr storages.Transaction
+		if err = rows.Scan(&tr.Id, &tr.UserId, &tr.Kind, &tr.Currency, &tr.Amount, &tr.Balance, &tr.CounterpartyId, &tr.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		result = append(result, tr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+func (p *PSQL) DeleteIdempotencyRecords(ctx context.Context, before time.Time) (int64, error) {
+	const op = "PSQL DeleteIdempotencyRecords"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	tag, err := p.pool.Exec(ctxWithTimeout, "delete from idempotency_keys where created_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (p *PSQL) CreateQuote(ctx context.Context, quote storages.Quote) (storages.Quote, error) {
+	const op = "PSQL CreateQuote"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	err := p.pool.QueryRow(ctxWithTimeout, `insert into exchange_quotes (user_id, from_currency, to_currency, amount, rate, to_amount, fee, expires_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8) returning id::text`,
+		quote.UserId, quote.FromCurrency, quote.ToCurrency, quote.Amount, quote.Rate, quote.ToAmount, quote.Fee, quote.ExpiresAt).Scan(&quote.Id)
+	if err != nil {
+		return storages.Quote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return quote, nil
+}
+
+func (p *PSQL) DeleteQuotes(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	const op = "PSQL DeleteQuotes"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	tag, err := p.pool.Exec(ctxWithTimeout, "delete from exchange_quotes where expires_at < $1", expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (p *PSQL) SaveRefreshToken(ctx context.Context, token storages.RefreshToken) error {
+	const op = "PSQL SaveRefreshToken"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	roles := token.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	_, err := p.pool.Exec(ctxWithTimeout, "insert into refresh_tokens (token_hash, user_id, roles, expires_at) values ($1, $2, $3, $4)",
+		token.Hash, token.UserId, roles, token.ExpiresAt)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// UseRefreshToken marks the token used and returns it. A token can be used
+// only once, so two concurrent refreshes with the same token cannot both succeed.
+func (p *PSQL) UseRefreshToken(ctx context.Context, hash string) (storages.RefreshToken, error) {
+	const op = "PSQL UseRefreshToken"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	token := storages.RefreshToken{Hash: hash}
+
+	err := p.pool.QueryRow(ctxWithTimeout, `update refresh_tokens set used_at = now()
+		where token_hash = $1 and used_at is null and expires_at > now()
+		returning user_id, roles, expires_at`, hash).Scan(&token.UserId, &token.Roles, &token.ExpiresAt)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return storages.RefreshToken{}, storages.RefreshTokenNotFoundErr
+	case err != nil:
+		return storages.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (p *PSQL) DeleteRefreshToken(ctx context.Context, user, hash string) error {
+	const op = "PSQL DeleteRefreshToken"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	_, err := p.pool.Exec(ctxWithTimeout, "delete from refresh_tokens where token_hash = $1 and user_id = $2", hash, user)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (p *PSQL) RevokeToken(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	const op = "PSQL RevokeToken"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	_, err := p.pool.Exec(ctxWithTimeout, "insert into revoked_tokens (token_id, expires_at) values ($1, $2) on conflict (token_id) do nothing",
+		tokenId, expiresAt)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (p *PSQL) IsTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	const op = "PSQL IsTokenRevoked"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	var revoked bool
+
+	err := p.pool.QueryRow(ctxWithTimeout, "select exists (select 1 from revoked_tokens where token_id = $1)", tokenId).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredTokens removes expired refresh tokens and revocations of tokens that have expired anyway.
+func (p *PSQL) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	const op = "PSQL DeleteExpiredTokens"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	var deleted int64
+
+	err := p.pool.BeginFunc(ctxWithTimeout, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctxWithTimeout, "delete from refresh_tokens where expires_at < $1", before)
+		if err != nil {
+			return err
+		}
+		deleted += tag.RowsAffected()
+
+		tag, err = tx.Exec(ctxWithTimeout, "delete from revoked_tokens where expires_at < $1", before)
+		if err != nil {
+			return err
+		}
+		deleted += tag.RowsAffected()
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func (p *PSQL) AddAuditRecord(ctx context.Context, record storages.AuditRecord) error {
+	const op = "PSQL AddAuditRecord"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	if err := addAuditRecord(ctxWithTimeout, p.pool, record); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func addAuditRecord(ctx context.Context, q querier, record storages.AuditRecord) error {
+	details := record.Details
+	if len(details) == 0 {
+		details = []byte("{}")
+	}
+
+	var id int64
+
+	return q.QueryRow(ctx, "insert into admin_audit (actor_id, action, user_id, reason, details) values ($1, $2, $3, $4, $5) returning id",
+		record.ActorId, record.Action, record.UserId, record.Reason, string(details)).Scan(&id)
+}
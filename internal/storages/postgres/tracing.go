@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gw-currency-wallet/internal/tracing"
+	"time"
+)
+
+var tracer = tracing.Tracer("gw-currency-wallet/internal/storages/postgres")
+
+// startSpan starts a span named after the operation and limits it with the
+// query timeout. The returned function ends both.
+func startSpan(ctx context.Context, op string, timeout time.Duration) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql")))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
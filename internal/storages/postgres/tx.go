@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+type tx struct {
+	timeout time.Duration
+	tx      pgx.Tx
+}
+
+// Transaction runs fn in a transaction limited by the query timeout as a whole.
+// fn gets the context of the transaction span, so the queries of the tx are its
+// children and share its deadline.
+func (p *PSQL) Transaction(ctx context.Context, fn func(context.Context, storages.Tx) error) error {
+	const op = "PSQL Transaction"
+
+	ctxWithTimeout, end := startSpan(ctx, op, p.timeout)
+	defer end()
+
+	err := p.pool.BeginFunc(ctxWithTimeout, func(pgxTx pgx.Tx) error {
+		return fn(ctxWithTimeout, &tx{timeout: p.timeout, tx: pgxTx})
+	})
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (t *tx) GetBalanceForUpdate(ctx context.Context, user string) (storages.Balance, error) {
+	const op = "PSQL Tx GetBalanceForUpdate"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	result, err := getBalance(ctxWithTimeout, t.tx, user, "for update")
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, err
+}
+
+func (t *tx) UpdateWallet(ctx context.Context, user string, balance storages.Balance) error {
+	const op = "PSQL Tx UpdateWallet"
+
+	if len(balance) == 0 {
+		return nil
+	}
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	batch := &pgx.Batch{}
+	for currency, amount := range balance {
+		batch.Queue("insert into balances (user_id, currency, amount) values ($1, $2, $3) on conflict (user_id, currency) do update set amount = excluded.amount",
+			user, currency, amount)
+	}
+
+	if err := t.tx.SendBatch(ctxWithTimeout, batch).Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (t *tx) AddTransactions(ctx context.Context, transactions ...storages.Transaction) error {
+	const op = "PSQL Tx AddTransactions"
+
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	batch := &pgx.Batch{}
+	for _, tr := range transactions {
+		batch.Queue("insert into wallet_transactions (user_id, kind, currency, amount, balance, counterparty_id) values ($1, $2, $3, $4, $5, nullif($6, ''))",
+			tr.UserId, tr.Kind, tr.Currency, tr.Amount, tr.Balance, tr.CounterpartyId)
+	}
+
+	if err := t.tx.SendBatch(ctxWithTimeout, batch).Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (t *tx) GetIdempotencyRecord(ctx context.Context, user, key string) (storages.IdempotencyRecord, bool, error) {
+	const op = "PSQL Tx GetIdempotencyRecord"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	record := storages.IdempotencyRecord{UserId: user, Key: key}
+	err := t.tx.QueryRow(ctxWithTimeout, "select request_hash, status_code, response, created_at from idempotency_keys where user_id = $1 and key = $2",
+		user, key).Scan(&record.RequestHash, &record.StatusCode, &record.Response, &record.CreatedAt)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return storages.IdempotencyRecord{}, false, nil
+	case err != nil:
+		return storages.IdempotencyRecord{}, false, fmt.Errorf("%s: %w", op, err)
+	default:
+		return record, true, nil
+	}
+}
+
+func (t *tx) SaveIdempotencyRecord(ctx context.Context, record storages.IdempotencyRecord) error {
+	const op = "PSQL Tx SaveIdempotencyRecord"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	_, err := t.tx.Exec(ctxWithTimeout, `insert into idempotency_keys (user_id, key, request_hash, status_code, response) values ($1, $2, $3, $4, $5)
+		on conflict (user_id, key) do update set request_hash = excluded.request_hash, status_code = excluded.status_code, response = excluded.response, created_at = now()`,
+		record.UserId, record.Key, record.RequestHash, record.StatusCode, record.Response)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (t *tx) GetQuoteForUpdate(ctx context.Context, id string) (storages.Quote, error) {
+	const op = "PSQL Tx GetQuoteForUpdate"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	var (
+		quote  = storages.Quote{Id: id}
+		usedAt *time.Time
+	)
+	err := t.tx.QueryRow(ctxWithTimeout, `select user_id, from_currency, to_currency, amount, rate, to_amount, fee, expires_at, used_at
+		from exchange_quotes where id = $1 for update`, id).
+		Scan(&quote.UserId, &quote.FromCurrency, &quote.ToCurrency, &quote.Amount, &quote.Rate, &quote.ToAmount, &quote.Fee, &quote.ExpiresAt, &usedAt)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return storages.Quote{}, storages.QuoteNotFoundErr
+	case err != nil:
+		return storages.Quote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt != nil {
+		quote.UsedAt = *usedAt
+	}
+
+	return quote, nil
+}
+
+func (t *tx) MarkQuoteUsed(ctx context.Context, id string) error {
+	const op = "PSQL Tx MarkQuoteUsed"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	_, err := t.tx.Exec(ctxWithTimeout, "update exchange_quotes set used_at = now() where id = $1", id)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (t *tx) GetWalletStatus(ctx context.Context, user string) (string, error) {
+	const op = "PSQL Tx GetWalletStatus"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	var status string
+
+	err := t.tx.QueryRow(ctxWithTimeout, "select status from wallets where user_id = $1", user).Scan(&status)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return "", storages.WalletNotFoundErr
+	case err != nil:
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return status, nil
+}
+
+func (t *tx) SetWalletStatus(ctx context.Context, user, status string) error {
+	const op = "PSQL Tx SetWalletStatus"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	_, err := t.tx.Exec(ctxWithTimeout, "update wallets set status = $2 where user_id = $1", user, status)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (t *tx) GetWalletTier(ctx context.Context, user string) (string, error) {
+	const op = "PSQL Tx GetWalletTier"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	var tier string
+
+	err := t.tx.QueryRow(ctxWithTimeout, "select tier from wallets where user_id = $1", user).Scan(&tier)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return "", storages.WalletNotFoundErr
+	case err != nil:
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tier, nil
+}
+
+func (t *tx) SetWalletTier(ctx context.Context, user, tier string) error {
+	const op = "PSQL Tx SetWalletTier"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	_, err := t.tx.Exec(ctxWithTimeout, "update wallets set tier = $2 where user_id = $1", user, tier)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// GetDebitTotal sums the debits as a positive amount.
+func (t *tx) GetDebitTotal(ctx context.Context, filter storages.DebitFilter) (decimal.Decimal, error) {
+	const op = "PSQL Tx GetDebitTotal"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	var total decimal.Decimal
+
+	err := t.tx.QueryRow(ctxWithTimeout, `select coalesce(sum(-amount), 0) from wallet_transactions
+		where user_id = $1 and currency = $2 and kind = any($3) and amount < 0 and created_at >= $4`,
+		filter.UserId, filter.Currency, filter.Kinds, filter.Since).Scan(&total)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return total, nil
+}
+
+func (t *tx) AddAuditRecord(ctx context.Context, record storages.AuditRecord) error {
+	const op = "PSQL Tx AddAuditRecord"
+
+	ctxWithTimeout, end := startSpan(ctx, op, t.timeout)
+	defer end()
+
+	if err := addAuditRecord(ctxWithTimeout, t.tx, record); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
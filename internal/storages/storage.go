@@ -2,16 +2,167 @@ package storages
 
 import (
 	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	KindDeposit     = "deposit"
+	KindWithdraw    = "withdraw"
+	KindExchange    = "exchange"
+	KindTransferIn  = "transfer_in"
+	KindTransferOut = "transfer_out"
+	KindFee         = "fee"
+	KindAdjustment  = "adjustment"
+)
+
+const (
+	WalletActive = "active"
+	WalletFrozen = "frozen"
+	WalletClosed = "closed"
+
+	DefaultTier = "unverified"
+)
+
+var (
+	WalletNotFoundErr = fmt.Errorf("wallet not found")
+	QuoteNotFoundErr  = fmt.Errorf("quote not found")
+	// RefreshTokenNotFoundErr is returned for unknown, expired and already used refresh tokens.
+	RefreshTokenNotFoundErr = fmt.Errorf("refresh token not found")
 )
 
 type Storage interface {
+	GetCurrencies(context.Context) ([]Currency, error)
 	GetBalance(context.Context, string) (Balance, error)
-	NewWallet(context.Context, string) error
+	NewWallet(ctx context.Context, id, username string) error
+	SetWalletUsername(ctx context.Context, id, username string) error
+	GetUserIdByUsername(context.Context, string) (string, error)
+	EnsureWallet(context.Context, string) error
+	Transaction(context.Context, func(context.Context, Tx) error) error
+	GetTransactions(context.Context, TransactionFilter) ([]Transaction, error)
+	DeleteIdempotencyRecords(ctx context.Context, before time.Time) (int64, error)
+	CreateQuote(context.Context, Quote) (Quote, error)
+	DeleteQuotes(ctx context.Context, expiredBefore time.Time) (int64, error)
+	SaveRefreshToken(context.Context, RefreshToken) error
+	UseRefreshToken(ctx context.Context, hash string) (RefreshToken, error)
+	DeleteRefreshToken(ctx context.Context, user, hash string) error
+	RevokeToken(ctx context.Context, tokenId string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, tokenId string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
+	GetWallet(context.Context, string) (Wallet, error)
+	AddAuditRecord(context.Context, AuditRecord) error
+}
+
+// Tx is a unit of work over wallets. Rows read through it stay locked until
+// the transaction commits or rolls back. Its methods are called with the
+// context passed to the Transaction callback, which bounds the whole transaction.
+type Tx interface {
+	GetBalanceForUpdate(context.Context, string) (Balance, error)
 	UpdateWallet(context.Context, string, Balance) error
+	AddTransactions(context.Context, ...Transaction) error
+	GetIdempotencyRecord(ctx context.Context, user, key string) (IdempotencyRecord, bool, error)
+	SaveIdempotencyRecord(context.Context, IdempotencyRecord) error
+	GetQuoteForUpdate(ctx context.Context, id string) (Quote, error)
+	MarkQuoteUsed(ctx context.Context, id string) error
+	GetWalletStatus(context.Context, string) (string, error)
+	SetWalletStatus(ctx context.Context, user, status string) error
+	GetWalletTier(context.Context, string) (string, error)
+	SetWalletTier(ctx context.Context, user, tier string) error
+	GetDebitTotal(context.Context, DebitFilter) (decimal.Decimal, error)
+	AddAuditRecord(context.Context, AuditRecord) error
+}
+
+type Currency struct {
+	Code       string
+	MinorUnits int32
+	Enabled    bool
+}
+
+// Balance maps a currency code to the amount held in it.
+// Currencies the wallet has never held are absent.
+type Balance map[string]decimal.Decimal
+
+// Transaction is a ledger entry. Amount is signed: negative for debits.
+// Balance is the currency balance right after the entry was applied.
+// CounterpartyId is the other wallet of a transfer, or the user who paid an exchange fee.
+type Transaction struct {
+	Id             int64           `json:"id"`
+	UserId         string          `json:"-"`
+	Kind           string          `json:"kind"`
+	Currency       string          `json:"currency"`
+	Amount         decimal.Decimal `json:"amount" swaggertype:"string"`
+	Balance        decimal.Decimal `json:"balance" swaggertype:"string"`
+	CounterpartyId string          `json:"counterparty_id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// TransactionFilter selects ledger entries of a user, newest first.
+// Zero-valued fields are not applied; BeforeId continues a previous page.
+type TransactionFilter struct {
+	UserId   string
+	Currency string
+	Kind     string
+	From     time.Time
+	To       time.Time
+	BeforeId int64
+	Limit    int
+}
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key.
+type IdempotencyRecord struct {
+	UserId      string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+	CreatedAt   time.Time
+}
+
+// Quote is an exchange rate offered to a user and locked until ExpiresAt.
+// UsedAt is zero while the quote has not been executed.
+type Quote struct {
+	Id           string
+	UserId       string
+	FromCurrency string
+	ToCurrency   string
+	Amount       decimal.Decimal
+	Rate         decimal.Decimal
+	ToAmount     decimal.Decimal
+	Fee          decimal.Decimal
+	ExpiresAt    time.Time
+	UsedAt       time.Time
+}
+
+// RefreshToken is stored by the hash of the opaque token handed to the client.
+type RefreshToken struct {
+	Hash      string
+	UserId    string
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+type Wallet struct {
+	UserId  string
+	Status  string
+	Tier    string
+	Balance Balance
+}
+
+// DebitFilter selects the debits of a wallet in one currency made since Since.
+type DebitFilter struct {
+	UserId   string
+	Currency string
+	Kinds    []string
+	Since    time.Time
 }
 
-type Balance struct {
-	USD,
-	RUB,
-	EUR float32
+// AuditRecord is an action of support or admin staff on a user's wallet.
+// Details is a JSON object.
+type AuditRecord struct {
+	ActorId string
+	Action  string
+	UserId  string
+	Reason  string
+	Details []byte
 }
@@ -0,0 +1,83 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"time"
+)
+
+var CannotIssueErr = fmt.Errorf("issuing tokens requires JWT_SHARED_KEY")
+
+// CanIssue reports whether the wallet can sign access tokens itself.
+func (v *Verifier) CanIssue() bool {
+	return v != nil && v.sharedKey != nil
+}
+
+// Issue signs an HS256 access token with the shared key, so it is accepted by
+// both the wallet and gw-authorizer.
+func (v *Verifier) Issue(userId string, roles []string, ttl time.Duration) (string, Claims, error) {
+	const op = "Tokens Issue"
+
+	if !v.CanIssue() {
+		return "", Claims{}, CannotIssueErr
+	}
+
+	tokenId, err := randomString(16)
+	if err != nil {
+		return "", Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	claims := Claims{
+		UserId:    userId,
+		Roles:     roles,
+		TokenId:   tokenId,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+
+	mapClaims := jwt.MapClaims{
+		"id":  userId,
+		"jti": tokenId,
+		"iat": now.Unix(),
+		"exp": claims.ExpiresAt.Unix(),
+	}
+	if len(roles) > 0 {
+		mapClaims["roles"] = roles
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString(v.sharedKey)
+	if err != nil {
+		return "", Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signed, claims, nil
+}
+
+// NewRefreshToken returns an opaque refresh token and the hash to store instead of it.
+func NewRefreshToken() (token, hash string, err error) {
+	if token, err = randomString(32); err != nil {
+		return "", "", err
+	}
+
+	return token, Hash(token), nil
+}
+
+// Hash is the sha256 of a token in hex. Tokens are stored and compared by hash only.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,138 @@
+package tokens
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+func (v *Verifier) refreshJWKS(ctx context.Context) error {
+	const op = "Tokens refreshJWKS"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", op, response.Status)
+	}
+
+	var document jwksDocument
+	if err = json.NewDecoder(response.Body).Decode(&document); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := parseJWKS(document)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	v.mu.Lock()
+	v.jwks = keys
+	v.mu.Unlock()
+
+	return nil
+}
+
+// parseJWKS keeps the RSA and EC signing keys that have a "kid"; other keys are skipped.
+func parseJWKS(document jwksDocument) (map[string]any, error) {
+	keys := make(map[string]any, len(document.Keys))
+
+	for _, key := range document.Keys {
+		if key.Kid == "" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			publicKey, err := rsaKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key.Kid, err)
+			}
+			keys[key.Kid] = publicKey
+		case "EC":
+			publicKey, err := ecKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key.Kid, err)
+			}
+			keys[key.Kid] = publicKey
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+
+	return keys, nil
+}
+
+func rsaKey(key jwk) (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(key.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeBigInt(key.E)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsInt64() || e.Int64() < 3 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecKey(key jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+
+	switch key.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+	}
+
+	x, err := decodeBigInt(key.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeBigInt(key.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("point is not on curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,54 @@
+package tokens
+
+import (
+	"fmt"
+	"gw-currency-wallet/pkg/logs"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	InvalidTokenErr = fmt.Errorf("invalid token")
+	ExpiredTokenErr = fmt.Errorf("token expired")
+)
+
+// Verifier checks token signatures and expiry locally. HMAC tokens are checked
+// with the shared key, RSA and ECDSA tokens with the key from the JWKS document
+// matching the "kid" header or, failing that, with the PEM public key.
+type Verifier struct {
+	sharedKey []byte
+	publicKey any
+
+	jwksURL  string
+	interval time.Duration
+	client   *http.Client
+	logger   *logs.Log
+
+	mu   sync.RWMutex
+	jwks map[string]any
+}
+
+// Claims are the claims the wallet relies on. Roles come from the "roles"
+// array or the single "role" claim, TokenId from "jti".
+type Claims struct {
+	UserId    string
+	Roles     []string
+	TokenId   string
+	ExpiresAt time.Time
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
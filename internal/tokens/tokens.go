@@ -0,0 +1,184 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/pkg/logs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func New(cfg config.JWTConfig, logger *logs.Log) (*Verifier, error) {
+	const op = "Tokens New"
+
+	v := &Verifier{
+		jwksURL:  cfg.JWKSURL,
+		interval: time.Duration(cfg.JWKSRefreshInterval) * time.Second,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+	}
+
+	if cfg.SharedKey != "" {
+		v.sharedKey = []byte(cfg.SharedKey)
+	}
+
+	if cfg.PublicKeyFile != "" {
+		raw, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if v.publicKey, err = parsePublicKey(raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return v, nil
+}
+
+// Run loads the JWKS document right away and then on every interval until ctx
+// is done. A failed refresh keeps the previous keys.
+func (v *Verifier) Run(ctx context.Context) {
+	const op = "Tokens Run"
+
+	if v.jwksURL == "" {
+		return
+	}
+
+	if err := v.refreshJWKS(ctx); err != nil {
+		v.logger.Err(ctx, op, err)
+	}
+
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.refreshJWKS(ctx); err != nil {
+				v.logger.Err(ctx, op, err)
+			}
+		}
+	}
+}
+
+// Verify checks the signature and requires an unexpired "exp" claim and a user "id".
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parsed, err := jwt.Parse(token, v.key)
+
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+		return Claims{}, ExpiredTokenErr
+	}
+	if err != nil || !parsed.Valid {
+		return Claims{}, InvalidTokenErr
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, InvalidTokenErr
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Claims{}, ExpiredTokenErr
+	}
+
+	return claimsFromMap(claims)
+}
+
+// ParseUnverified reads the claims without checking the signature. It is only
+// for tokens that are then verified by gw-authorizer.
+func ParseUnverified(token string) (Claims, error) {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return Claims{}, InvalidTokenErr
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, InvalidTokenErr
+	}
+
+	return claimsFromMap(claims)
+}
+
+func claimsFromMap(claims jwt.MapClaims) (Claims, error) {
+	id, ok := claims["id"]
+	if !ok || id == nil || fmt.Sprint(id) == "" {
+		return Claims{}, InvalidTokenErr
+	}
+
+	result := Claims{UserId: fmt.Sprint(id)}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		result.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if jti, ok := claims["jti"].(string); ok {
+		result.TokenId = jti
+	}
+
+	switch roles := claims["roles"].(type) {
+	case []any:
+		for _, role := range roles {
+			if role, ok := role.(string); ok && role != "" {
+				result.Roles = append(result.Roles, role)
+			}
+		}
+	case string:
+		result.Roles = strings.Fields(roles)
+	}
+
+	if role, ok := claims["role"].(string); ok && role != "" {
+		result.Roles = append(result.Roles, role)
+	}
+
+	return result, nil
+}
+
+// key picks the verification key by the token's algorithm family, so an HMAC
+// token can never be checked against a public key.
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.sharedKey == nil {
+			return nil, fmt.Errorf("no shared key for %s", token.Method.Alg())
+		}
+		return v.sharedKey, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+			v.mu.RLock()
+			key, found := v.jwks[kid]
+			v.mu.RUnlock()
+			if found {
+				return key, nil
+			}
+		}
+
+		if v.publicKey == nil {
+			return nil, fmt.Errorf("no public key for %s", token.Method.Alg())
+		}
+		return v.publicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+func parsePublicKey(raw []byte) (any, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(raw); err == nil {
+		return key, nil
+	}
+
+	key, err := jwt.ParseECPublicKeyFromPEM(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key")
+	}
+
+	return key, nil
+}
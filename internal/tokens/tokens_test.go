@@ -0,0 +1,201 @@
+package tokens
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"gw-currency-wallet/internal/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	return signed
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	keyFile := filepath.Join(t.TempDir(), "public.pem")
+	if err = os.WriteFile(keyFile, publicPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"kid": "ec-1",
+		"use": "sig",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	}, {
+		"kty": "RSA",
+		"kid": "rsa-2",
+		"n":   base64.RawURLEncoding.EncodeToString(otherKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(otherKey.E)).Bytes()),
+	}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	verifier, err := New(config.JWTConfig{
+		PublicKeyFile:       keyFile,
+		SharedKey:           "secret",
+		JWKSURL:             server.URL,
+		JWKSRefreshInterval: 300,
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err = verifier.refreshJWKS(context.Background()); err != nil {
+		t.Fatalf("refreshJWKS() error = %v", err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"id": "42", "exp": exp}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "HS256 с общим ключом",
+			token: sign(t, jwt.SigningMethodHS256, []byte("secret"), "", valid),
+		},
+		{
+			name:  "RS256 с ключом из PEM",
+			token: sign(t, jwt.SigningMethodRS256, rsaKey, "", valid),
+		},
+		{
+			name:  "ES256 с ключом из JWKS",
+			token: sign(t, jwt.SigningMethodES256, ecKey, "ec-1", valid),
+		},
+		{
+			name:  "RS256 с ключом из JWKS",
+			token: sign(t, jwt.SigningMethodRS256, otherKey, "rsa-2", valid),
+		},
+		{
+			name:    "неизвестный ключ",
+			token:   sign(t, jwt.SigningMethodRS256, otherKey, "", valid),
+			wantErr: true,
+		},
+		{
+			name:    "неверный общий ключ",
+			token:   sign(t, jwt.SigningMethodHS256, []byte("other"), "", valid),
+			wantErr: true,
+		},
+		{
+			name:    "HS256 с открытым ключом вместо общего",
+			token:   sign(t, jwt.SigningMethodHS256, publicPEM, "", valid),
+			wantErr: true,
+		},
+		{
+			name:    "истекший токен",
+			token:   sign(t, jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"id": "42", "exp": time.Now().Add(-time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "токен без exp",
+			token:   sign(t, jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"id": "42"}),
+			wantErr: true,
+		},
+		{
+			name:    "токен без id",
+			token:   sign(t, jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "alg none",
+			token:   sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", valid),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && claims.UserId != "42" {
+				t.Errorf("Verify() UserId = %v, want 42", claims.UserId)
+			}
+		})
+	}
+}
+
+func TestVerifier_Issue(t *testing.T) {
+	withoutKey, err := New(config.JWTConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = withoutKey.Issue("42", nil, time.Minute); err != CannotIssueErr {
+		t.Errorf("Issue() without shared key error = %v, want %v", err, CannotIssueErr)
+	}
+
+	verifier, err := New(config.JWTConfig{SharedKey: "secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, issued, err := verifier.Issue("42", []string{"admin"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.UserId != "42" || claims.TokenId != issued.TokenId || len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+		t.Errorf("Verify() claims = %+v, want %+v", claims, issued)
+	}
+	if !claims.ExpiresAt.Equal(issued.ExpiresAt) {
+		t.Errorf("Verify() ExpiresAt = %v, want %v", claims.ExpiresAt, issued.ExpiresAt)
+	}
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/pkg/logs"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// accessLog writes one record per request. Server errors are logged at the
+// error level and slow requests at warn; the other probe requests are sampled.
+type accessLog struct {
+	logger      *logs.Log
+	slow        time.Duration
+	probeSample uint64
+	probes      atomic.Uint64
+}
+
+func newAccessLog(logger *logs.Log, cfg config.AccessLogConfig) *accessLog {
+	return &accessLog{
+		logger:      logger,
+		slow:        time.Duration(cfg.SlowThreshold) * time.Millisecond,
+		probeSample: uint64(max(cfg.ProbeSample, 0)),
+	}
+}
+
+func (l *accessLog) handle(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	latency := time.Since(start)
+	status := c.Writer.Status()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	args := []any{
+		"method", c.Request.Method,
+		"route", route,
+		"status", status,
+		"latency_ms", float64(latency.Microseconds()) / 1000,
+		"bytes", max(c.Writer.Size(), 0),
+		"client_ip", c.ClientIP(),
+	}
+
+	ctx := c.Request.Context()
+
+	switch {
+	case status >= http.StatusInternalServerError:
+		l.logger.Error(ctx, "request", args...)
+	case l.slow > 0 && latency >= l.slow:
+		l.logger.Warn(ctx, "slow request", args...)
+	case isProbe(route) && !l.sampled():
+	default:
+		l.logger.Info(ctx, "request", args...)
+	}
+}
+
+func (l *accessLog) sampled() bool {
+	if l.probeSample == 0 {
+		return false
+	}
+
+	return (l.probes.Add(1)-1)%l.probeSample == 0
+}
+
+// isProbe reports the routes polled by the orchestrator and by Prometheus.
+func isProbe(route string) bool {
+	return route == "/healthz" || route == "/readyz" || route == "/metrics"
+}
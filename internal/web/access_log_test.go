@@ -0,0 +1,62 @@
+package web
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/pkg/logs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		delay     time.Duration
+		requests  int
+		wantLines int
+		wantLevel string
+	}{
+		{name: "обычный запрос", path: "/api/v1/wallet/balance", status: http.StatusOK, requests: 1, wantLines: 1, wantLevel: "INFO"},
+		{name: "ошибка сервера", path: "/api/v1/wallet/balance", status: http.StatusInternalServerError, requests: 1, wantLines: 1, wantLevel: "ERROR"},
+		{name: "медленный запрос", path: "/api/v1/wallet/balance", status: http.StatusOK, delay: 20 * time.Millisecond, requests: 1, wantLines: 1, wantLevel: "WARN"},
+		{name: "проверки состояния выборочно", path: "/healthz", status: http.StatusOK, requests: 5, wantLines: 2, wantLevel: "INFO"},
+		{name: "неудачная проверка состояния", path: "/readyz", status: http.StatusServiceUnavailable, requests: 3, wantLines: 3, wantLevel: "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			accessLog := newAccessLog(logs.New(&buf, logs.FormatJSON, slog.LevelInfo), config.AccessLogConfig{SlowThreshold: 10, ProbeSample: 3})
+
+			router := gin.New()
+			router.Use(accessLog.handle)
+			router.GET(tt.path, func(c *gin.Context) {
+				time.Sleep(tt.delay)
+				c.Status(tt.status)
+			})
+
+			for range tt.requests {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if buf.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != tt.wantLines {
+				t.Fatalf("got %d records, want %d: %s", len(lines), tt.wantLines, buf.String())
+			}
+			if !strings.Contains(lines[0], `"level":"`+tt.wantLevel+`"`) || !strings.Contains(lines[0], `"route":"`+tt.path+`"`) {
+				t.Errorf("unexpected record %s", lines[0])
+			}
+		})
+	}
+}
@@ -2,12 +2,35 @@ package web
 
 import "github.com/gin-gonic/gin"
 
+// Probes answer the liveness and readiness checks of the orchestrator.
+type Probes interface {
+	Live(ctx *gin.Context)
+	Ready(ctx *gin.Context)
+}
+
 type Handler interface {
+	Authenticate(ctx *gin.Context)
+	RateLimit(ctx *gin.Context)
+	RateLimitIP(ctx *gin.Context)
 	Register(ctx *gin.Context)
 	Login(ctx *gin.Context)
+	Refresh(ctx *gin.Context)
+	Logout(ctx *gin.Context)
 	Balance(ctx *gin.Context)
 	Deposit(ctx *gin.Context)
 	Withdraw(ctx *gin.Context)
+	Transfer(ctx *gin.Context)
+	Transactions(ctx *gin.Context)
 	Rates(ctx *gin.Context)
 	Exchange(ctx *gin.Context)
+	Quote(ctx *gin.Context)
+	RequireSupport(ctx *gin.Context)
+	RequireAdmin(ctx *gin.Context)
+	AdminWallet(ctx *gin.Context)
+	AdminTransactions(ctx *gin.Context)
+	FreezeWallet(ctx *gin.Context)
+	UnfreezeWallet(ctx *gin.Context)
+	CloseWallet(ctx *gin.Context)
+	SetWalletTier(ctx *gin.Context)
+	AdjustWallet(ctx *gin.Context)
 }
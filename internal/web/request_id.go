@@ -0,0 +1,35 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"gw-currency-wallet/pkg/logs"
+	"regexp"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// validRequestId limits the ids accepted from the client, since they end up in the logs.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// requestId keeps the X-Request-ID of the request or generates one, returns it
+// in the response header and stores it in the request context for the logger.
+func requestId(c *gin.Context) {
+	id := c.GetHeader(requestIdHeader)
+	if !validRequestId.MatchString(id) {
+		id = newRequestId()
+	}
+
+	c.Header(requestIdHeader, id)
+	c.Request = c.Request.WithContext(logs.ContextWithRequestId(c.Request.Context(), id))
+
+	c.Next()
+}
+
+func newRequestId() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
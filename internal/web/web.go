@@ -7,30 +7,84 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/metrics"
+	"gw-currency-wallet/pkg/logs"
+	"io"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	_ "gw-currency-wallet/internal/docs"
 )
 
+const serviceName = "gw-currency-wallet"
+
 type Gin struct {
 	srv *http.Server
 }
 
-func New(url string, handler Handler) Gin {
-	router := gin.Default()
+func New(url string, trustedProxies []string, handler Handler, probes Probes, logger *logs.Log, accessLog config.AccessLogConfig) (Gin, error) {
+	const op = "Web New"
+
 	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return Gin{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	router.Use(requestId)
+	router.Use(newAccessLog(logger, accessLog).handle)
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logger.Error(c.Request.Context(), "panic recovered", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(otelgin.Middleware(serviceName, otelgin.WithFilter(traced)))
+	router.Use(metrics.Gin)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/healthz", probes.Live)
+	router.GET("/readyz", probes.Ready)
+
+	public := router.Group("/api/v1", handler.RateLimit)
+	public.POST("/register", handler.Register)
+	public.POST("/login", handler.Login)
+	public.POST("/token/refresh", handler.Refresh)
+
+	api := router.Group("/api/v1", handler.RateLimitIP, handler.Authenticate, handler.RateLimit)
+	api.POST("/logout", handler.Logout)
+	api.GET("/wallet/balance", handler.Balance)
+	api.POST("/wallet/deposit", handler.Deposit)
+	api.POST("/wallet/withdraw", handler.Withdraw)
+	api.POST("/wallet/transfer", handler.Transfer)
+	api.GET("/wallet/transactions", handler.Transactions)
+	api.GET("/exchange/rates", handler.Rates)
+	api.POST("/exchange", handler.Exchange)
+	api.POST("/exchange/quote", handler.Quote)
+
+	admin := api.Group("/admin", handler.RequireSupport)
+	admin.GET("/wallets/:user_id", handler.AdminWallet)
+	admin.GET("/wallets/:user_id/transactions", handler.AdminTransactions)
+	admin.POST("/wallets/:user_id/freeze", handler.RequireAdmin, handler.FreezeWallet)
+	admin.POST("/wallets/:user_id/unfreeze", handler.RequireAdmin, handler.UnfreezeWallet)
+	admin.POST("/wallets/:user_id/close", handler.RequireAdmin, handler.CloseWallet)
+	admin.POST("/wallets/:user_id/tier", handler.RequireAdmin, handler.SetWalletTier)
+	admin.POST("/wallets/:user_id/adjustments", handler.RequireAdmin, handler.AdjustWallet)
 
-	router.POST("/api/v1/register", handler.Register)
-	router.POST("/api/v1/login", handler.Login)
-	router.GET("/api/v1/wallet/balance", handler.Balance)
-	router.POST("/api/v1/wallet/deposit", handler.Deposit)
-	router.POST("/api/v1/wallet/withdraw", handler.Withdraw)
-	router.GET("/api/v1/exchange/rates", handler.Rates)
-	router.POST("/api/v1/exchange", handler.Exchange)
 	router.GET("swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	return Gin{srv: &http.Server{Addr: url, Handler: router.Handler()}}
+	return Gin{srv: &http.Server{Addr: url, Handler: router.Handler()}}, nil
+}
+
+// traced leaves the probes and the metrics scrapes out of the traces.
+func traced(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/metrics", "/healthz", "/readyz":
+		return false
+	}
+
+	return true
 }
 
 func (g *Gin) Start() error {
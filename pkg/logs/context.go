@@ -0,0 +1,55 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+)
+
+type (
+	requestIdKey struct{}
+	userIdKey    struct{}
+)
+
+func ContextWithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the id stored by ContextWithRequestId or an empty string.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+func ContextWithUserId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIdKey{}, id)
+}
+
+// UserId returns the id stored by ContextWithUserId or an empty string.
+func UserId(ctx context.Context) string {
+	id, _ := ctx.Value(userIdKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request and the user ids of the context to the record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if id := UserId(ctx); id != "" {
+		record.AddAttrs(slog.String("user_id", id))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,29 +1,67 @@
 package logs
 
 import (
+	"context"
 	"io"
 	"log/slog"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 type Log struct {
 	logger *slog.Logger
 }
-type Attr struct {
-	Key   string
-	Value string
-}
 
-func New(w io.Writer) *Log {
-	logger := slog.New(slog.NewTextHandler(w, nil))
+// New writes records of at least the level as text or, with FormatJSON, as
+// JSON lines. Secrets and emails are redacted and the request and the user
+// ids of the context are added to every record.
+func New(w io.Writer, format string, level slog.Level) *Log {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	logger := slog.New(contextHandler{handler})
 	slog.SetDefault(logger)
 	return &Log{logger: logger}
 }
 
-func (l *Log) Err(msg string, err error) {
-	slog.Error(msg, "error", err.Error())
+// ParseLevel accepts debug, info, warn and error.
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	return level, err
+}
+
+// With returns a logger that adds the attributes to every record.
+func (l *Log) With(args ...any) *Log {
+	return &Log{logger: l.logger.With(args...)}
 }
 
-func (l *Log) Info(msg string, attr Attr) {
+func (l *Log) Debug(ctx context.Context, msg string, args ...any) {
+	l.logger.DebugContext(ctx, msg, args...)
+}
+
+func (l *Log) Info(ctx context.Context, msg string, args ...any) {
+	l.logger.InfoContext(ctx, msg, args...)
+}
+
+func (l *Log) Warn(ctx context.Context, msg string, args ...any) {
+	l.logger.WarnContext(ctx, msg, args...)
+}
+
+func (l *Log) Error(ctx context.Context, msg string, args ...any) {
+	l.logger.ErrorContext(ctx, msg, args...)
+}
 
-	slog.Info(msg, attr.Key, attr.Value)
+// Err logs err at the error level.
+func (l *Log) Err(ctx context.Context, msg string, err error, args ...any) {
+	l.logger.ErrorContext(ctx, msg, append([]any{"error", err}, args...)...)
 }
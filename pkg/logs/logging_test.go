@@ -0,0 +1,79 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLog_redaction(t *testing.T) {
+	type payload struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	tests := []struct {
+		name    string
+		log     func(l *Log)
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "пароль и email в теле запроса",
+			log: func(l *Log) {
+				l.Info(context.Background(), "register", "request", payload{Username: "bob", Password: "qwerty", Email: "bob@example.com"})
+			},
+			want:    []string{`"username":"bob"`, `"password":"[REDACTED]"`, `"email":"b***@example.com"`},
+			notWant: []string{"qwerty", "bob@example.com"},
+		},
+		{
+			name: "токен в атрибуте",
+			log: func(l *Log) {
+				l.Info(context.Background(), "refresh", "refresh_token", "abc.def.ghi")
+			},
+			want:    []string{`"refresh_token":"[REDACTED]"`},
+			notWant: []string{"abc.def.ghi"},
+		},
+		{
+			name: "email в тексте ошибки",
+			log: func(l *Log) {
+				l.Err(context.Background(), "create user", errors.New("alice@example.com already exists"))
+			},
+			want:    []string{`"error":"a***@example.com already exists"`},
+			notWant: []string{"alice@example.com"},
+		},
+		{
+			name: "идентификатор запроса из контекста",
+			log: func(l *Log) {
+				l.Warn(ContextWithRequestId(context.Background(), "req-1"), "slow")
+			},
+			want: []string{`"request_id":"req-1"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(New(&buf, FormatJSON, slog.LevelInfo))
+
+			line := buf.String()
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("not a JSON line: %s", line)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(line, want) {
+					t.Errorf("%s does not contain %s", line, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(line, notWant) {
+					t.Errorf("%s contains %s", line, notWant)
+				}
+			}
+		})
+	}
+}
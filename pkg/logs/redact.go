@@ -0,0 +1,98 @@
+package logs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// secretKeys are matched as substrings of the lower-cased attribute or field name.
+var secretKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maskEmails keeps the first letter and the domain: "a***@example.com".
+func maskEmails(value string) string {
+	return emailPattern.ReplaceAllStringFunc(value, func(email string) string {
+		return email[:1] + "***" + email[strings.IndexByte(email, '@'):]
+	})
+}
+
+// redactAttr hides secrets and emails. Structs, maps and slices are logged
+// through their JSON form, so that their fields are redacted as well.
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if isSecret(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, maskEmails(attr.Value.String()))
+	case slog.KindAny:
+		value := attr.Value.Any()
+		if err, ok := value.(error); ok {
+			return slog.String(attr.Key, maskEmails(err.Error()))
+		}
+
+		if payload, ok := redactPayload(value); ok {
+			return slog.Any(attr.Key, payload)
+		}
+	}
+
+	return attr
+}
+
+func redactPayload(value any) (any, bool) {
+	kind := reflect.Indirect(reflect.ValueOf(value)).Kind()
+	if kind != reflect.Struct && kind != reflect.Map && kind != reflect.Slice {
+		return nil, false
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	var decoded any
+	if err = json.Unmarshal(raw, &decoded); err != nil {
+		return nil, false
+	}
+
+	return redactValue(decoded), true
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if isSecret(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	case string:
+		return maskEmails(v)
+	}
+
+	return value
+}